@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// requireAdmin only lets through requests whose Authorization header carries
+// ADMIN_TOKEN, either bare or as a bearer token. Admin endpoints are locked
+// entirely when no token is configured.
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, ERR_UNAUTHORIZED, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleCache evicts a single spot with DELETE /cache?spotId=.., or every
+// entry with DELETE /cache
+func handleCache(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodDelete) {
+		return
+	}
+
+	var evicted int
+	if spotID := r.URL.Query().Get("spotId"); spotID != "" {
+		evicted = forecastCache.Delete(spotID)
+	} else {
+		evicted = forecastCache.Clear()
+	}
+	slog.InfoContext(r.Context(), "cache evicted", "event", "cache_evicted", "spotId", r.URL.Query().Get("spotId"), "evicted", evicted)
+
+	writeJSONResponse(w, r, map[string]int{"evicted": evicted})
+}
+
+// handleCacheStats reports cache hit and miss counts with GET /cache/stats
+func handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSONResponse(w, r, forecastCache.Stats())
+}
+
+// handleCacheStatsReset zeroes the cache counters with POST /cache/stats/reset
+func handleCacheStatsReset(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	forecastCache.ResetStats()
+	slog.InfoContext(r.Context(), "cache stats reset", "event", "cache_stats_reset")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CacheRefreshResult reports a POST /cache/refresh: how many spots were
+// refetched, and why each of the rest failed
+type CacheRefreshResult struct {
+	Refreshed int               `json:"refreshed"`
+	Errors    map[string]string `json:"errors"`
+}
+
+// handleCacheRefresh refetches every known spot from the provider and
+// replaces its cache entry, e.g. after the source corrects its data.
+// Disabled spots are left alone.
+func handleCacheRefresh(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	result := CacheRefreshResult{Errors: make(map[string]string)}
+	for _, spot := range knownSpots.List() {
+		if disabledSpots[spot.SpotID] {
+			continue
+		}
+		if _, err := getForecast(r.Context(), spot.SpotID, true); err != nil {
+			slog.WarnContext(r.Context(), "cache refresh failed", "event", "refresh_failed", "spotId", spot.SpotID, "error", err)
+			result.Errors[spot.SpotID] = fetchErrorMessage(err)
+			continue
+		}
+		result.Refreshed++
+	}
+	slog.InfoContext(r.Context(), "cache refreshed", "event", "cache_refreshed", "refreshed", result.Refreshed, "failed", len(result.Errors))
+
+	writeJSONResponse(w, r, result)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"bearer token", "s3cret", "Bearer s3cret", http.StatusNoContent},
+		{"bare token", "s3cret", "s3cret", http.StatusNoContent},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"no header", "s3cret", "", http.StatusUnauthorized},
+		{"no token configured", "", "", http.StatusUnauthorized},
+		{"no token configured, bearer sent", "", "Bearer ", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &adminToken, tt.token)
+			handler := requireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			req := httptest.NewRequest(http.MethodDelete, "/cache", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestHandleCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		want        int
+		wantEvicted int
+		wantLeft    int
+	}{
+		{"one spot", http.MethodDelete, "?spotId=" + malibu, http.StatusOK, 1, 1},
+		{"uncached spot", http.MethodDelete, "?spotId=" + tamarindo, http.StatusOK, 0, 2},
+		{"everything", http.MethodDelete, "", http.StatusOK, 2, 0},
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := useCache(t)
+			expiresAt := time.Now().Unix() + 60
+			cache.Set(malibu, fakeForecast(malibu, 3), expiresAt)
+			cache.Set(huntington, fakeForecast(huntington, 3), expiresAt)
+
+			w := httptest.NewRecorder()
+			handleCache(w, httptest.NewRequest(tt.method, "/cache"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := len(cache.items); got != tt.wantLeft {
+				t.Errorf("%d entries left, want %d", got, tt.wantLeft)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var body map[string]int
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if body["evicted"] != tt.wantEvicted {
+				t.Errorf("evicted = %d, want %d", body["evicted"], tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestHandleCacheStats(t *testing.T) {
+	cache := useCache(t)
+	setForTest(t, &adminToken, "s3cret")
+	setForTest(t, &rateLimitPerMin, 0)
+	handler := newHandler()
+
+	// Two misses, each followed by hits
+	for _, spotID := range []string{malibu, malibu, malibu, malibu, huntington} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+spotID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("forecast status = %d: %s", w.Code, w.Body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cache/stats", nil))
+	var stats CacheStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	if stats.Entries != 2 || stats.Hits != 3 || stats.Misses != 2 || stats.HitRatio != 0.6 {
+		t.Errorf("stats = %+v, want 2 entries, 3 hits, 2 misses and a 0.6 ratio", stats)
+	}
+	if stats.OldestExpiry <= time.Now().Unix() {
+		t.Errorf("OldestExpiry = %d, want in the future", stats.OldestExpiry)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cache/stats", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodGet {
+		t.Errorf("POST /cache/stats = %d (Allow %q), want 405 allowing GET", w.Code, w.Header().Get("Allow"))
+	}
+
+	reset := func(authorization string) int {
+		r := httptest.NewRequest(http.MethodPost, "/cache/stats/reset", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := reset(""); code != http.StatusUnauthorized {
+		t.Errorf("reset without the admin token = %d, want 401", code)
+	}
+	if code := reset("Bearer s3cret"); code != http.StatusNoContent {
+		t.Fatalf("reset status = %d, want 204", code)
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 || stats.Entries != 2 || stats.HitRatio != 0 {
+		t.Errorf("stats after reset = %+v, want the entries kept and counters zeroed", stats)
+	}
+}
+
+func TestHandleCacheRefresh(t *testing.T) {
+	provider := newFakeProvider()
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		if spotID == tamarindo {
+			return ForecastResponse{}, errors.New("upstream unavailable")
+		}
+		return fakeForecast(spotID, 3), nil
+	}
+	useProvider(t, provider)
+	useSpots(t)
+	setForTest(t, &disabledSpots, map[string]bool{huntington: true})
+
+	// A cached entry is refetched regardless
+	forecastCache.Set(malibu, fakeForecast(malibu, 1), time.Now().Unix()+60)
+
+	w := httptest.NewRecorder()
+	handleCacheRefresh(w, httptest.NewRequest(http.MethodPost, "/cache/refresh", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var result CacheRefreshResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	if want := len(defaultSpots) - 2; result.Refreshed != want {
+		t.Errorf("Refreshed = %d, want %d", result.Refreshed, want)
+	}
+	if len(result.Errors) != 1 || result.Errors[tamarindo] == "" {
+		t.Errorf("Errors = %v, want only %s", result.Errors, tamarindo)
+	}
+	if provider.Calls(huntington) != 0 {
+		t.Errorf("disabled spot was fetched")
+	}
+	if cached, _ := forecastCache.Get(malibu); cached.WaveHeightFt != 3 {
+		t.Errorf("cached WaveHeightFt = %v, want the refetched 3", cached.WaveHeightFt)
+	}
+}
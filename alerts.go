@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Default seconds between alert checks when REFRESH_INTERVAL_SECONDS is not
+// set, see ALERT_CHECK_INTERVAL_SECONDS
+const DEFAULT_ALERT_CHECK_INTERVAL_SECONDS = 300
+
+// alertsPath returns where alerts are saved next to the cache file, so
+// cache.json keeps its alerts in cache.alerts.json. Without a cache file
+// alerts are kept in memory only, like the cache itself.
+func alertsPath(cacheFile string) string {
+	if cacheFile == "" {
+		return ""
+	}
+	return strings.TrimSuffix(cacheFile, filepath.Ext(cacheFile)) + ".alerts.json"
+}
+
+// Most alerts a single email address can subscribe
+const MAX_ALERTS_PER_EMAIL = 20
+
+// Alert asks for a notification when a spot's waves reach MinWaveFt
+type Alert struct {
+	ID        string  `json:"id"`
+	SpotID    string  `json:"spotId"`
+	MinWaveFt float64 `json:"minWaveFt"`
+	Email     string  `json:"email"`
+
+	// Shared by every alert of an email and needed to list them, so knowing
+	// an address isn't enough to see what it subscribed to
+	Token string `json:"token"`
+}
+
+// alertNotifier delivers a fired alert
+type alertNotifier interface {
+	Notify(ctx context.Context, alert Alert, forecast ForecastResponse) error
+}
+
+// logNotifier stands in for real delivery by logging each fired alert
+type logNotifier struct{}
+
+func (logNotifier) Notify(ctx context.Context, alert Alert, forecast ForecastResponse) error {
+	slog.InfoContext(ctx, "alert conditions met", "event", "alert_fired", "alertId", alert.ID, "spotId", alert.SpotID, "waveHeightFt", forecast.WaveHeightFt, "minWaveFt", alert.MinWaveFt)
+	return nil
+}
+
+// alertStore keeps the alert subscriptions, written through to a JSON file
+// on every change when a path is configured. It also remembers which alerts
+// have fired, so each one notifies once per swell rather than every refresh.
+type alertStore struct {
+	mu       sync.Mutex
+	path     string
+	alerts   map[string]Alert
+	firing   map[string]bool
+	notifier alertNotifier
+}
+
+// newAlertStore loads any alerts previously saved at path. An empty path
+// keeps alerts in memory only.
+func newAlertStore(path string, notifier alertNotifier) (*alertStore, error) {
+	store := &alertStore{path: path, alerts: make(map[string]Alert), firing: make(map[string]bool), notifier: notifier}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved []Alert
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("parsing alerts file %s: %w", path, err)
+	}
+	for _, alert := range saved {
+		store.alerts[alert.ID] = alert
+	}
+	return store, nil
+}
+
+var errTooManyAlerts = errors.New("too many alerts for this email")
+
+// Add stores a new alert under a fresh ID and persists the change. The
+// email's first alert gets a new token, which later ones share.
+func (s *alertStore) Add(alert Alert) (Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	token := ""
+	for _, existing := range s.alerts {
+		if existing.Email == alert.Email {
+			count++
+			token = existing.Token
+		}
+	}
+	if count >= MAX_ALERTS_PER_EMAIL {
+		return Alert{}, errTooManyAlerts
+	}
+	if token == "" {
+		token = newUUID()
+	}
+
+	alert.ID = newUUID()
+	alert.Token = token
+	s.alerts[alert.ID] = alert
+	if err := s.save(); err != nil {
+		delete(s.alerts, alert.ID)
+		return Alert{}, err
+	}
+	return alert, nil
+}
+
+// Remove deletes an alert, reporting whether it existed
+func (s *alertStore) Remove(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert, ok := s.alerts[id]
+	if !ok {
+		return false, nil
+	}
+	delete(s.alerts, id)
+	delete(s.firing, id)
+	if err := s.save(); err != nil {
+		s.alerts[id] = alert
+		return false, err
+	}
+	return true, nil
+}
+
+// List returns the alerts subscribed by email, sorted by ID
+func (s *alertStore) List(email string) []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := []Alert{}
+	for _, alert := range s.alerts {
+		if alert.Email == email {
+			matches = append(matches, alert)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	return matches
+}
+
+// Authorized reports whether token is the one handed out with email's
+// alerts. An address without alerts has no token to match.
+func (s *alertStore) Authorized(email, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, alert := range s.alerts {
+		if alert.Email == email {
+			return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(alert.Token)) == 1
+		}
+	}
+	return false
+}
+
+// SpotIDs returns the distinct spots that have alerts on them, sorted
+func (s *alertStore) SpotIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var spotIDs []string
+	for _, alert := range s.alerts {
+		if !seen[alert.SpotID] {
+			seen[alert.SpotID] = true
+			spotIDs = append(spotIDs, alert.SpotID)
+		}
+	}
+	sort.Strings(spotIDs)
+	return spotIDs
+}
+
+// Check evaluates the alerts on a freshly fetched forecast. An alert fires
+// when the waves first reach its threshold and is re-armed once they drop
+// back below it.
+func (s *alertStore) Check(ctx context.Context, forecast ForecastResponse) {
+	s.mu.Lock()
+	var fired []Alert
+	for id, alert := range s.alerts {
+		if alert.SpotID != forecast.SpotID {
+			continue
+		}
+		met := forecast.Error == "" && forecast.WaveHeightFt >= alert.MinWaveFt
+		if met && !s.firing[id] {
+			fired = append(fired, alert)
+		}
+		s.firing[id] = met
+	}
+	s.mu.Unlock()
+
+	for _, alert := range fired {
+		if err := s.notifier.Notify(ctx, alert, forecast); err != nil {
+			slog.WarnContext(ctx, "could not deliver alert", "event", "alert_failed", "alertId", alert.ID, "error", err)
+		}
+	}
+}
+
+// save writes the alerts to s.path. The caller must hold s.mu.
+func (s *alertStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	saved := make([]Alert, 0, len(s.alerts))
+	for _, alert := range s.alerts {
+		saved = append(saved, alert)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].ID < saved[j].ID })
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// Alert subscriptions, replaced in main once those saved next to CACHE_FILE
+// are loaded
+var alerts = &alertStore{alerts: make(map[string]Alert), firing: make(map[string]bool), notifier: logNotifier{}}
+
+// handleAlerts creates an alert on POST with a {"spotId","minWaveFt","email"}
+// body, lists an address's alerts on GET ?email=&token= and removes one on
+// DELETE ?id=. The token comes back from POST, the same for every alert of
+// one email.
+func handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		email := r.URL.Query().Get("email")
+		if email == "" {
+			writeJSONError(w, http.StatusBadRequest, ERR_MISSING_PARAMETER, "Missing email parameter")
+			return
+		}
+		if !alerts.Authorized(email, r.URL.Query().Get("token")) {
+			writeJSONError(w, http.StatusUnauthorized, ERR_UNAUTHORIZED, "Missing or invalid alert token")
+			return
+		}
+		writeJSONResponse(w, r, alerts.List(email))
+	case http.MethodPost:
+		// An alert nothing will ever check is worse than none
+		if refreshInterval <= 0 && alertCheckInterval <= 0 {
+			writeJSONError(w, http.StatusNotImplemented, ERR_NOT_SUPPORTED, "Alert checks are disabled on this server")
+			return
+		}
+		var body Alert
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		switch {
+		case !validSpotID(body.SpotID):
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+			return
+		case body.MinWaveFt <= 0:
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "minWaveFt must be positive")
+			return
+		case !validEmail(body.Email):
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Missing or invalid email")
+			return
+		}
+		if _, ok := lookupSpot(r.Context(), body.SpotID); !ok {
+			writeJSONError(w, http.StatusBadRequest, ERR_UNKNOWN_SPOT, "unknown spotId "+body.SpotID)
+			return
+		}
+
+		alert, err := alerts.Add(Alert{SpotID: body.SpotID, MinWaveFt: body.MinWaveFt, Email: body.Email})
+		if errors.Is(err, errTooManyAlerts) {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, fmt.Sprintf("At most %d alerts are allowed per email", MAX_ALERTS_PER_EMAIL))
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "could not save alerts", "event", "alerts_save_failed", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Failed to save alert")
+			return
+		}
+		slog.InfoContext(r.Context(), "alert created", "event", "alert_added", "alertId", alert.ID, "spotId", alert.SpotID)
+
+		writeJSONStatus(w, r, http.StatusCreated, alert)
+	case http.MethodDelete:
+		removed, err := alerts.Remove(r.URL.Query().Get("id"))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "could not save alerts", "event", "alerts_save_failed", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Failed to remove alert")
+			return
+		}
+		if !removed {
+			writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_ALERT, "unknown alert id")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validEmail reports whether s is a bare email address such as
+// surfer@example.com
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingNotifier keeps the IDs of the alerts it was asked to deliver
+type recordingNotifier struct {
+	mu    sync.Mutex
+	fired []string
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert Alert, forecast ForecastResponse) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.fired = append(n.fired, alert.ID)
+	return nil
+}
+
+// useAlerts gives the test an empty in-memory alert store of its own
+func useAlerts(t *testing.T) (*alertStore, *recordingNotifier) {
+	t.Helper()
+	notifier := &recordingNotifier{}
+	store, err := newAlertStore("", notifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &alerts, store)
+	return store, notifier
+}
+
+func TestAlertsPath(t *testing.T) {
+	tests := []struct {
+		cacheFile string
+		want      string
+	}{
+		{"", ""},
+		{"cache.json", "cache.alerts.json"},
+		{"/var/lib/surf/cache.json", "/var/lib/surf/cache.alerts.json"},
+		{"cache", "cache.alerts.json"},
+	}
+	for _, tt := range tests {
+		if got := alertsPath(tt.cacheFile); got != tt.want {
+			t.Errorf("alertsPath(%q) = %q, want %q", tt.cacheFile, got, tt.want)
+		}
+	}
+}
+
+func TestAlertStoreCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		heights   []float64
+		wantFires int
+	}{
+		{"never reached", []float64{2, 3, 3.9}, 0},
+		{"fires once while it holds", []float64{4, 5, 6}, 1},
+		{"re-arms after dropping", []float64{4, 3, 4.5}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, notifier := useAlerts(t)
+			if _, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "surfer@example.com"}); err != nil {
+				t.Fatal(err)
+			}
+			for _, height := range tt.heights {
+				store.Check(context.Background(), fakeForecast(malibu, height))
+				// Other spots' forecasts never fire it
+				store.Check(context.Background(), fakeForecast(huntington, 10))
+			}
+			if got := len(notifier.fired); got != tt.wantFires {
+				t.Errorf("fired %d times, want %d", got, tt.wantFires)
+			}
+		})
+	}
+}
+
+func TestAlertStoreCheckSkipsErrors(t *testing.T) {
+	store, notifier := useAlerts(t)
+	if _, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "surfer@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	failed := fakeForecast(malibu, 6)
+	failed.Error = "upstream unavailable"
+	store.Check(context.Background(), failed)
+
+	if len(notifier.fired) != 0 {
+		t.Errorf("alert fired on a failed forecast")
+	}
+}
+
+func TestAlertStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.alerts.json")
+	store, err := newAlertStore(path, logNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "surfer@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := store.Add(Alert{SpotID: tamarindo, MinWaveFt: 6, Email: "surfer@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.Remove(removed.ID); !ok || err != nil {
+		t.Fatalf("Remove() = %v, %v", ok, err)
+	}
+
+	reloaded, err := newAlertStore(path, logNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reloaded.List("surfer@example.com")
+	if len(got) != 1 || got[0] != kept {
+		t.Errorf("reloaded alerts = %+v, want [%+v]", got, kept)
+	}
+	if !reloaded.Authorized("surfer@example.com", kept.Token) {
+		t.Error("token was not kept across a reload")
+	}
+	if spotIDs := reloaded.SpotIDs(); len(spotIDs) != 1 || spotIDs[0] != malibu {
+		t.Errorf("SpotIDs() = %v, want [%s]", spotIDs, malibu)
+	}
+}
+
+func TestNewAlertStoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.alerts.json")
+	if err := os.WriteFile(corrupt, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"in memory", "", false},
+		{"not saved yet", filepath.Join(dir, "missing.alerts.json"), false},
+		{"corrupt file", corrupt, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newAlertStore(tt.path, logNotifier{}); (err != nil) != tt.wantErr {
+				t.Errorf("newAlertStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlertStoreTokens(t *testing.T) {
+	store, _ := useAlerts(t)
+	first, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "surfer@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Add(Alert{SpotID: tamarindo, MinWaveFt: 6, Email: "surfer@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "other@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Token == "" || second.Token != first.Token {
+		t.Errorf("tokens = %q, %q, want one shared token", first.Token, second.Token)
+	}
+	if other.Token == first.Token {
+		t.Error("two emails share a token")
+	}
+
+	tests := []struct {
+		name  string
+		email string
+		token string
+		want  bool
+	}{
+		{"own token", "surfer@example.com", first.Token, true},
+		{"no token", "surfer@example.com", "", false},
+		{"another email's token", "surfer@example.com", other.Token, false},
+		{"unknown email", "nobody@example.com", first.Token, false},
+		{"unknown email without token", "nobody@example.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.Authorized(tt.email, tt.token); got != tt.want {
+				t.Errorf("Authorized(%q, %q) = %v, want %v", tt.email, tt.token, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertStoreLimitPerEmail(t *testing.T) {
+	store, _ := useAlerts(t)
+	for i := 0; i < MAX_ALERTS_PER_EMAIL; i++ {
+		if _, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "surfer@example.com"}); err != nil {
+			t.Fatalf("alert %d: %v", i, err)
+		}
+	}
+	if _, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "surfer@example.com"}); err != errTooManyAlerts {
+		t.Errorf("Add() past the limit error = %v, want %v", err, errTooManyAlerts)
+	}
+	if _, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 4, Email: "other@example.com"}); err != nil {
+		t.Errorf("Add() for another email error = %v", err)
+	}
+}
+
+func TestHandleAlerts(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		interval time.Duration
+		want     int
+		wantCode string
+	}{
+		{"create", http.MethodPost, "/alerts", `{"spotId":"` + malibu + `","minWaveFt":4,"email":"surfer@example.com"}`, time.Minute, http.StatusCreated, ""},
+		{"checks disabled", http.MethodPost, "/alerts", `{"spotId":"` + malibu + `","minWaveFt":4,"email":"surfer@example.com"}`, 0, http.StatusNotImplemented, ERR_NOT_SUPPORTED},
+		{"invalid spot", http.MethodPost, "/alerts", `{"spotId":"nope","minWaveFt":4,"email":"surfer@example.com"}`, time.Minute, http.StatusBadRequest, ERR_INVALID_SPOT_ID},
+		{"unknown spot", http.MethodPost, "/alerts", `{"spotId":"` + unknownSpotID + `","minWaveFt":4,"email":"surfer@example.com"}`, time.Minute, http.StatusBadRequest, ERR_UNKNOWN_SPOT},
+		{"no threshold", http.MethodPost, "/alerts", `{"spotId":"` + malibu + `","minWaveFt":0,"email":"surfer@example.com"}`, time.Minute, http.StatusBadRequest, ERR_INVALID_PARAMETER},
+		{"named email", http.MethodPost, "/alerts", `{"spotId":"` + malibu + `","minWaveFt":4,"email":"Surfer <surfer@example.com>"}`, time.Minute, http.StatusBadRequest, ERR_INVALID_PARAMETER},
+		{"list without token", http.MethodGet, "/alerts?email=surfer@example.com", "", time.Minute, http.StatusUnauthorized, ERR_UNAUTHORIZED},
+		{"list with wrong token", http.MethodGet, "/alerts?email=surfer@example.com&token=nope", "", time.Minute, http.StatusUnauthorized, ERR_UNAUTHORIZED},
+		{"list without email", http.MethodGet, "/alerts", "", time.Minute, http.StatusBadRequest, ERR_MISSING_PARAMETER},
+		{"remove unknown", http.MethodDelete, "/alerts?id=nope", "", time.Minute, http.StatusNotFound, ERR_UNKNOWN_ALERT},
+		{"wrong method", http.MethodPut, "/alerts", "", time.Minute, http.StatusMethodNotAllowed, ERR_METHOD_NOT_ALLOWED},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useAlerts(t)
+			useNegativeCache(t)
+			setForTest(t, &refreshInterval, 0)
+			setForTest(t, &alertCheckInterval, tt.interval)
+
+			w := httptest.NewRecorder()
+			handleAlerts(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantCode != "" {
+				if got := errorCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %q, want %q", got, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleAlertsLifecycle(t *testing.T) {
+	useAlerts(t)
+	setForTest(t, &alertCheckInterval, time.Minute)
+
+	w := httptest.NewRecorder()
+	handleAlerts(w, httptest.NewRequest(http.MethodPost, "/alerts", strings.NewReader(`{"spotId":"`+malibu+`","minWaveFt":4,"email":"surfer@example.com"}`)))
+	var created Alert
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.ID == "" || created.Token == "" {
+		t.Fatalf("create = %s, %v", w.Body, err)
+	}
+
+	list := func() []Alert {
+		w := httptest.NewRecorder()
+		handleAlerts(w, httptest.NewRequest(http.MethodGet, "/alerts?email=surfer@example.com&token="+created.Token, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("list status = %d, want 200: %s", w.Code, w.Body)
+		}
+		var listed []Alert
+		if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+			t.Fatalf("decoding list %q: %v", w.Body, err)
+		}
+		return listed
+	}
+	if listed := list(); len(listed) != 1 || listed[0] != created {
+		t.Fatalf("listed = %+v, want [%+v]", listed, created)
+	}
+
+	w = httptest.NewRecorder()
+	handleAlerts(w, httptest.NewRequest(http.MethodDelete, "/alerts?id="+created.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want 204", w.Code)
+	}
+	// With its last alert gone the email has no token left to list with
+	if listed := alerts.List("surfer@example.com"); len(listed) != 0 {
+		t.Errorf("listed after delete = %+v", listed)
+	}
+}
+
+func TestValidEmail(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"surfer@example.com", true},
+		{"Surfer <surfer@example.com>", false},
+		{"surfer", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validEmail(tt.s); got != tt.want {
+			t.Errorf("validEmail(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
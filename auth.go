@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// requireAPIKey only lets through requests whose X-Api-Key header is one of
+// apiKeys when REQUIRE_API_KEY is enabled, and passes everything through
+// otherwise. Keys are only ever logged as a short hash.
+func requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !apiKeyRequired {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-Api-Key")
+		if !validAPIKey(key) {
+			slog.WarnContext(r.Context(), "rejected API key", "event", "api_key_rejected", "keyHash", apiKeyHash(key))
+			writeJSONError(w, http.StatusUnauthorized, ERR_UNAUTHORIZED, "Missing or invalid API key")
+			return
+		}
+		slog.DebugContext(r.Context(), "accepted API key", "event", "api_key_accepted", "keyHash", apiKeyHash(key))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAPIKey reports whether key is one of apiKeys, comparing in constant
+// time
+func validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	valid := false
+	for _, candidate := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// apiKeyHash identifies a key in logs without revealing it
+func apiKeyHash(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
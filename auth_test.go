@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		key      string
+		want     int
+	}{
+		{"not required", false, "", http.StatusNoContent},
+		{"valid key", true, "key-one", http.StatusNoContent},
+		{"second valid key", true, "key-two", http.StatusNoContent},
+		{"wrong key", true, "key-three", http.StatusUnauthorized},
+		{"missing key", true, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &apiKeyRequired, tt.required)
+			setForTest(t, &apiKeys, []string{"key-one", "key-two"})
+			handler := requireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/forecast", nil)
+			if tt.key != "" {
+				req.Header.Set("X-Api-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+				if body["error"] != "Missing or invalid API key" {
+					t.Errorf("error = %q", body["error"])
+				}
+			}
+		})
+	}
+}
+
+func TestValidAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		key  string
+		want bool
+	}{
+		{"listed", []string{"key-one"}, "key-one", true},
+		{"prefix of a key", []string{"key-one"}, "key", false},
+		{"empty key", []string{"key-one", ""}, "", false},
+		{"no keys configured", nil, "key-one", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &apiKeys, tt.keys)
+			if got := validAPIKey(tt.key); got != tt.want {
+				t.Errorf("validAPIKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyHash(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", ""},
+		// First four bytes of sha256("key-one")
+		{"key-one", "9b346041"},
+	}
+	for _, tt := range tests {
+		got := apiKeyHash(tt.key)
+		if got != tt.want {
+			t.Errorf("apiKeyHash(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+	if apiKeyHash("key-one") == apiKeyHash("key-two") {
+		t.Error("different keys hash the same")
+	}
+}
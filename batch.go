@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Default limit on spots per POST /forecast/batch, see MAX_BATCH_SIZE
+const DEFAULT_MAX_BATCH_SIZE = 50
+
+type batchRequest struct {
+	SpotIDs []string `json:"spotIds"`
+}
+
+// handleBatch serves the forecasts for the spots listed in a JSON body of
+// the form {"spotIds":[...]}, for batches too large for a query string.
+// Like GET /forecast, spots that fail are flagged rather than failing the
+// whole batch.
+func handleBatch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var body batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(body.SpotIDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing spotIds")
+		return
+	}
+	if len(body.SpotIDs) > maxBatchSize {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, fmt.Sprintf("At most %d spotIds are allowed per batch", maxBatchSize))
+		return
+	}
+	for _, spotID := range body.SpotIDs {
+		if !validSpotID(spotID) {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+			return
+		}
+	}
+
+	units := r.URL.Query().Get("units")
+	if units == "" {
+		units = UNITS_IMPERIAL
+	}
+	if units != UNITS_IMPERIAL && units != UNITS_METRIC {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid units parameter, expected imperial or metric")
+		return
+	}
+	bypassCache, _ := strconv.ParseBool(r.URL.Query().Get("bypassCache"))
+
+	writeForecastResponse(w, r, getForecasts(r.Context(), body.SpotIDs, bypassCache, units))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHandleBatch(t *testing.T) {
+	tooMany := make([]string, DEFAULT_MAX_BATCH_SIZE+1)
+	for i := range tooMany {
+		tooMany[i] = `"` + malibu + `"`
+	}
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		body      string
+		want      int
+		wantErr   string
+		wantSpots []string
+	}{
+		{"forecasts in order", http.MethodPost, "/forecast/batch", `{"spotIds":["` + huntington + `","` + malibu + `"]}`, http.StatusOK, "", []string{huntington, malibu}},
+		{"unknown spot is flagged", http.MethodPost, "/forecast/batch", `{"spotIds":["` + unknownSpotID + `","` + malibu + `"]}`, http.StatusOK, "", []string{unknownSpotID, malibu}},
+		{"metric", http.MethodPost, "/forecast/batch?units=metric", `{"spotIds":["` + malibu + `"]}`, http.StatusOK, "", []string{malibu}},
+		{"empty", http.MethodPost, "/forecast/batch", `{"spotIds":[]}`, http.StatusBadRequest, "Missing spotIds", nil},
+		{"too many", http.MethodPost, "/forecast/batch", `{"spotIds":[` + strings.Join(tooMany, ",") + `]}`, http.StatusBadRequest, "At most 50 spotIds are allowed per batch", nil},
+		{"invalid spot", http.MethodPost, "/forecast/batch", `{"spotIds":["nope"]}`, http.StatusBadRequest, "invalid spotId format", nil},
+		{"invalid units", http.MethodPost, "/forecast/batch?units=furlongs", `{"spotIds":["` + malibu + `"]}`, http.StatusBadRequest, "Invalid units parameter, expected imperial or metric", nil},
+		{"malformed body", http.MethodPost, "/forecast/batch", `{"spotIds":`, http.StatusBadRequest, "Invalid JSON body", nil},
+		{"wrong method", http.MethodGet, "/forecast/batch", "", http.StatusMethodNotAllowed, "Method not allowed", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, newFakeProvider())
+			w := httptest.NewRecorder()
+			handleBatch(w, httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantErr != "" {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+				if body["error"] != tt.wantErr {
+					t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+				}
+				return
+			}
+			var responses []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			var got []string
+			for _, response := range responses {
+				got = append(got, response.SpotID)
+				if response.SpotID == unknownSpotID && response.Error == "" {
+					t.Error("unknown spot was not flagged")
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantSpots, ",") {
+				t.Errorf("spots = %v, want %v", got, tt.wantSpots)
+			}
+		})
+	}
+}
+
+func TestHandleBatchConfiguredSize(t *testing.T) {
+	useProvider(t, newFakeProvider())
+	t.Setenv("MAX_BATCH_SIZE", "2")
+	setForTest(t, &maxBatchSize, maxBatchSize)
+	loadConfig()
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"spotIds":["` + malibu + `","` + huntington + `"]}`, http.StatusOK},
+		{`{"spotIds":["` + malibu + `","` + huntington + `","` + tamarindo + `"]}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		handleBatch(w, httptest.NewRequest(http.MethodPost, "/forecast/batch", strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestGetForecastsCoalescesBatches(t *testing.T) {
+	provider := newFakeProvider()
+	provider.hold = make(chan struct{})
+	useProvider(t, provider)
+
+	// The same set of spots in different orders and with repeats
+	batches := [][]string{
+		{malibu, huntington, tamarindo},
+		{tamarindo, huntington, malibu},
+		{huntington, malibu, tamarindo, malibu},
+	}
+	const callers = 30
+	var wg sync.WaitGroup
+	results := make([][]ForecastResponse, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = getForecasts(context.Background(), batches[i%len(batches)], true, UNITS_IMPERIAL)
+		}(i)
+	}
+
+	// One shared assembly fetches spots in turn, so while the first fetch is
+	// held no other spot has been asked for
+	for totalCalls(provider) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := totalCalls(provider); got != 1 {
+		t.Errorf("%d fetches in flight, want the single shared one", got)
+	}
+	close(provider.hold)
+	wg.Wait()
+
+	for _, spotID := range []string{malibu, huntington, tamarindo} {
+		if got := provider.Calls(spotID); got != 1 {
+			t.Errorf("%s fetched %d times, want 1", spotID, got)
+		}
+	}
+	for i, responses := range results {
+		batch := batches[i%len(batches)]
+		for j, response := range responses {
+			if response.SpotID != batch[j] {
+				t.Fatalf("caller %d got %s at %d, want %s", i, response.SpotID, j, batch[j])
+			}
+		}
+	}
+}
+
+// totalCalls sums fakeProvider's fetches across every spot
+func totalCalls(p *fakeProvider) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	for _, calls := range p.calls {
+		total += calls
+	}
+	return total
+}
+
+func TestGetForecastsCallerGivesUp(t *testing.T) {
+	provider := newFakeProvider()
+	provider.hold = make(chan struct{})
+	useProvider(t, provider)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	responses := getForecasts(ctx, []string{malibu, huntington}, false, UNITS_IMPERIAL)
+
+	for _, response := range responses {
+		if response.Error != fetchErrorMessage(context.DeadlineExceeded) {
+			t.Errorf("%s Error = %q, want a timeout", response.SpotID, response.Error)
+		}
+		if response.ApiVersion != API_VERSION {
+			t.Errorf("%s ApiVersion = %q, want %q", response.SpotID, response.ApiVersion, API_VERSION)
+		}
+	}
+
+	// The shared assembly carries on without the caller; joining it waits
+	// for it to finish before the test's state is torn down
+	close(provider.hold)
+	for _, response := range getForecasts(context.Background(), []string{malibu, huntington}, false, UNITS_IMPERIAL) {
+		if response.Error != "" {
+			t.Errorf("%s Error = %q after the assembly finished", response.SpotID, response.Error)
+		}
+	}
+}
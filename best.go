@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// bestForecast picks the highest-scoring forecast, breaking ties by spot ID so
+// the result is deterministic. Entries that could not be served are skipped.
+func bestForecast(forecasts []ForecastResponse) (ForecastResponse, bool) {
+	var best ForecastResponse
+	found := false
+	for _, forecast := range forecasts {
+		if forecast.Error != "" {
+			continue
+		}
+		if !found || forecast.Score > best.Score || (forecast.Score == best.Score && forecast.SpotID < best.SpotID) {
+			best = forecast
+			found = true
+		}
+	}
+	return best, found
+}
+
+// handleBest serves the forecast with the best conditions among the spots
+// listed in ?spots=a,b,c, as rated by rateConditions
+func handleBest(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	spotIDs := parseSpotIDs(r.URL.Query().Get("spots"))
+	if len(spotIDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_PARAMETER, "Missing spots parameter")
+		return
+	}
+	for _, spotID := range spotIDs {
+		if !validSpotID(spotID) {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+			return
+		}
+	}
+
+	units := r.URL.Query().Get("units")
+	if units == "" {
+		units = UNITS_IMPERIAL
+	}
+	if units != UNITS_IMPERIAL && units != UNITS_METRIC {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid units parameter, expected imperial or metric")
+		return
+	}
+	bypassCache, _ := strconv.ParseBool(r.URL.Query().Get("bypassCache"))
+
+	best, ok := bestForecast(getForecasts(r.Context(), spotIDs, bypassCache, units))
+	if !ok {
+		writeJSONError(w, http.StatusBadGateway, ERR_UPSTREAM_ERROR, "No forecasts available for the listed spots")
+		return
+	}
+	writeForecastResponse(w, r, best)
+}
+
+type CompareResponse struct {
+	A ForecastResponse `json:"a"`
+	B ForecastResponse `json:"b"`
+
+	// "a" or "b", whichever rates better; ties go to a
+	Recommendation string `json:"recommendation"`
+}
+
+// handleCompare serves two spots' forecasts side by side with
+// /forecast/compare?a=..&b=.., recommending the better rated one
+func handleCompare(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	spotIDs := make(map[string]string, 2)
+	for _, param := range []string{"a", "b"} {
+		spotID := r.URL.Query().Get(param)
+		if spotID == "" {
+			writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing "+param+" parameter")
+			return
+		}
+		if !validSpotID(spotID) {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format for "+param)
+			return
+		}
+		if _, ok := lookupSpot(r.Context(), spotID); !ok {
+			writeJSONError(w, http.StatusBadRequest, ERR_UNKNOWN_SPOT, "unknown spotId for "+param)
+			return
+		}
+		spotIDs[param] = spotID
+	}
+
+	units := r.URL.Query().Get("units")
+	if units == "" {
+		units = UNITS_IMPERIAL
+	}
+	if units != UNITS_IMPERIAL && units != UNITS_METRIC {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid units parameter, expected imperial or metric")
+		return
+	}
+	bypassCache, _ := strconv.ParseBool(r.URL.Query().Get("bypassCache"))
+
+	forecasts := make(map[string]ForecastResponse, 2)
+	for _, param := range []string{"a", "b"} {
+		spotID := spotIDs[param]
+		forecast, err := getForecast(r.Context(), spotID, bypassCache)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error fetching forecast", "event", "fetch_failed", "spotId", spotID, "error", err)
+			writeFetchError(w, err)
+			return
+		}
+		if units == UNITS_METRIC {
+			forecast = toMetric(forecast)
+		}
+		forecasts[param] = forecast
+	}
+
+	resp := CompareResponse{A: forecasts["a"], B: forecasts["b"], Recommendation: "a"}
+	if resp.B.Score > resp.A.Score {
+		resp.Recommendation = "b"
+	}
+	writeJSONResponse(w, r, resp)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBestForecast(t *testing.T) {
+	tests := []struct {
+		name      string
+		forecasts []ForecastResponse
+		wantSpot  string
+		wantFound bool
+	}{
+		{"none", nil, "", false},
+		{"highest score", []ForecastResponse{{SpotID: "b", Score: 40}, {SpotID: "a", Score: 70}}, "a", true},
+		{"tie goes to the lower ID", []ForecastResponse{{SpotID: "b", Score: 70}, {SpotID: "a", Score: 70}}, "a", true},
+		{"errors are skipped", []ForecastResponse{{SpotID: "a", Score: 90, Error: "upstream unavailable"}, {SpotID: "b", Score: 10}}, "b", true},
+		{"only errors", []ForecastResponse{{SpotID: "a", Error: "upstream unavailable"}}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, found := bestForecast(tt.forecasts)
+			if found != tt.wantFound || best.SpotID != tt.wantSpot {
+				t.Errorf("bestForecast() = %q, %v, want %q, %v", best.SpotID, found, tt.wantSpot, tt.wantFound)
+			}
+		})
+	}
+}
+
+// heightsProvider serves waves of the given height for each spot and fails
+// for any other
+func heightsProvider(heights map[string]float64) *fakeProvider {
+	provider := newFakeProvider()
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		height, ok := heights[spotID]
+		if !ok {
+			return ForecastResponse{}, errors.New("upstream unavailable")
+		}
+		return fakeForecast(spotID, height), nil
+	}
+	return provider
+}
+
+func TestHandleBest(t *testing.T) {
+	tests := []struct {
+		name     string
+		heights  map[string]float64
+		query    string
+		want     int
+		wantErr  string
+		wantSpot string
+	}{
+		{"bigger waves win", map[string]float64{malibu: 2, huntington: 5}, "?spots=" + malibu + "," + huntington, http.StatusOK, "", huntington},
+		{"tie goes to the lower ID", map[string]float64{malibu: 4, huntington: 4}, "?spots=" + huntington + "," + malibu, http.StatusOK, "", malibu},
+		{"failed spot is skipped", map[string]float64{malibu: 2}, "?spots=" + malibu + "," + huntington, http.StatusOK, "", malibu},
+		{"metric", map[string]float64{malibu: 2}, "?spots=" + malibu + "&units=metric", http.StatusOK, "", malibu},
+		{"all failed", nil, "?spots=" + malibu, http.StatusBadGateway, "No forecasts available for the listed spots", ""},
+		{"missing spots", nil, "", http.StatusBadRequest, "Missing spots parameter", ""},
+		{"invalid spot", nil, "?spots=" + malibu + ",nope", http.StatusBadRequest, "invalid spotId format", ""},
+		{"invalid units", nil, "?spots=" + malibu + "&units=furlongs", http.StatusBadRequest, "Invalid units parameter, expected imperial or metric", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, heightsProvider(tt.heights))
+
+			w := httptest.NewRecorder()
+			handleBest(w, httptest.NewRequest(http.MethodGet, "/forecast/best"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantErr != "" {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+				if body["error"] != tt.wantErr {
+					t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+				}
+				return
+			}
+			var best ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &best); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if best.SpotID != tt.wantSpot || best.Score == 0 {
+				t.Errorf("best = %s scoring %d, want %s", best.SpotID, best.Score, tt.wantSpot)
+			}
+		})
+	}
+}
+
+func TestHandleCompare(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		want      int
+		wantErr   string
+		wantPick  string
+		wantUnits string
+	}{
+		{"b rates better", "?a=" + malibu + "&b=" + huntington, http.StatusOK, "", "b", UNITS_IMPERIAL},
+		{"a rates better", "?a=" + huntington + "&b=" + malibu, http.StatusOK, "", "a", UNITS_IMPERIAL},
+		{"tie goes to a", "?a=" + malibu + "&b=" + malibu, http.StatusOK, "", "a", UNITS_IMPERIAL},
+		{"metric", "?a=" + malibu + "&b=" + huntington + "&units=metric", http.StatusOK, "", "b", UNITS_METRIC},
+		{"missing b", "?a=" + malibu, http.StatusBadRequest, "Missing b parameter", "", ""},
+		{"invalid a", "?a=nope&b=" + malibu, http.StatusBadRequest, "invalid spotId format for a", "", ""},
+		{"unknown b", "?a=" + malibu + "&b=" + unknownSpotID, http.StatusBadRequest, "unknown spotId for b", "", ""},
+		{"invalid units", "?a=" + malibu + "&b=" + huntington + "&units=furlongs", http.StatusBadRequest, "Invalid units parameter, expected imperial or metric", "", ""},
+		{"fetch fails", "?a=" + malibu + "&b=" + tamarindo, http.StatusBadGateway, "Failed to fetch forecast", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, heightsProvider(map[string]float64{malibu: 2, huntington: 5}))
+
+			w := httptest.NewRecorder()
+			handleCompare(w, httptest.NewRequest(http.MethodGet, "/forecast/compare"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantErr != "" {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+				if body["error"] != tt.wantErr {
+					t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+				}
+				return
+			}
+			var resp CompareResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if resp.Recommendation != tt.wantPick {
+				t.Errorf("Recommendation = %q, want %q (scores %d, %d)", resp.Recommendation, tt.wantPick, resp.A.Score, resp.B.Score)
+			}
+			if resp.A.SpotID == "" || resp.B.SpotID == "" || resp.A.ApiVersion != API_VERSION {
+				t.Errorf("incomplete forecasts a = %+v, b = %+v", resp.A, resp.B)
+			}
+			if resp.A.Units != tt.wantUnits || resp.B.Units != tt.wantUnits {
+				t.Errorf("Units = %q, %q, want %q", resp.A.Units, resp.B.Units, tt.wantUnits)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for the provider circuit breaker, see CIRCUIT_BREAKER_THRESHOLD
+// and CIRCUIT_BREAKER_COOLDOWN_SECONDS
+const (
+	DEFAULT_CIRCUIT_BREAKER_THRESHOLD        = 5
+	DEFAULT_CIRCUIT_BREAKER_COOLDOWN_SECONDS = 30
+)
+
+// errCircuitOpen is returned instead of calling a provider that keeps failing
+var errCircuitOpen = errors.New("forecast source unavailable, circuit breaker open")
+
+// circuitBreaker stops calling the provider after threshold consecutive
+// failures. Once cooldown has passed it lets a single probe through: success
+// closes the breaker again, failure reopens it for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	open      bool
+	probing   bool
+}
+
+// newCircuitBreaker returns a breaker, or one that never opens when
+// threshold is not positive
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports errCircuitOpen while the breaker is open or a probe is
+// already in flight
+func (b *circuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return nil
+	}
+	if b.probing || appClock.Now().Sub(b.openedAt) < b.cooldown {
+		return errCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// Record updates the breaker with the outcome of an allowed call. Errors that
+// say nothing about the provider's health, such as a 4xx for one spot, don't
+// count as failures.
+func (b *circuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !providerFailure(err) {
+		if b.open {
+			slog.Info("circuit breaker closed", "event", "circuit_closed")
+		}
+		b.failures = 0
+		b.open = false
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.threshold > 0 && (b.probing || b.failures >= b.threshold) {
+		if !b.open || b.probing {
+			slog.Warn("circuit breaker opened", "event", "circuit_opened", "failures", b.failures, "cooldown", b.cooldown)
+		}
+		b.open = true
+		b.probing = false
+		b.openedAt = appClock.Now()
+	}
+}
+
+// providerFailure reports whether a fetch error suggests the provider itself
+// is unhealthy
+func providerFailure(err error) bool {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// Breaker guarding forecastProvider, replaced in main once its thresholds
+// are known
+var providerBreaker = newCircuitBreaker(DEFAULT_CIRCUIT_BREAKER_THRESHOLD, DEFAULT_CIRCUIT_BREAKER_COOLDOWN_SECONDS*time.Second)
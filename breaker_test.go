@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failure := errors.New("connection refused")
+	notFound := &upstreamStatusError{Resource: "wave", StatusCode: http.StatusNotFound}
+	tests := []struct {
+		name    string
+		records []error
+		advance time.Duration
+		want    error
+	}{
+		{"closed below threshold", []error{failure, failure}, 0, nil},
+		{"opens at threshold", []error{failure, failure, failure}, 0, errCircuitOpen},
+		{"success resets failures", []error{failure, failure, nil, failure}, 0, nil},
+		{"client errors don't count", []error{notFound, notFound, notFound}, 0, nil},
+		{"stays open during cooldown", []error{failure, failure, failure}, 29 * time.Second, errCircuitOpen},
+		{"probes after cooldown", []error{failure, failure, failure}, 30 * time.Second, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := useFakeClock(t, time.Unix(1_700_000_000, 0))
+			breaker := newCircuitBreaker(3, 30*time.Second)
+			for _, err := range tt.records {
+				breaker.Record(err)
+			}
+			clock.Advance(tt.advance)
+			if got := breaker.Allow(); got != tt.want {
+				t.Errorf("Allow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe error
+		want  error
+	}{
+		{"successful probe closes", nil, nil},
+		{"failed probe reopens", errors.New("timeout"), errCircuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := useFakeClock(t, time.Unix(1_700_000_000, 0))
+			breaker := newCircuitBreaker(1, time.Minute)
+			breaker.Record(errors.New("timeout"))
+			clock.Advance(time.Minute)
+
+			if err := breaker.Allow(); err != nil {
+				t.Fatalf("probe Allow() = %v, want nil", err)
+			}
+			if err := breaker.Allow(); err != errCircuitOpen {
+				t.Fatalf("second Allow() during probe = %v, want %v", err, errCircuitOpen)
+			}
+			breaker.Record(tt.probe)
+			if got := breaker.Allow(); got != tt.want {
+				t.Errorf("Allow() after probe = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleForecastCircuitOpen(t *testing.T) {
+	provider := newFakeProvider()
+	var down bool
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		if down {
+			return ForecastResponse{}, &upstreamStatusError{Resource: "wave", StatusCode: http.StatusServiceUnavailable}
+		}
+		return fakeForecast(spotID, 4), nil
+	}
+	useProvider(t, provider)
+	clock := useFakeClock(t, time.Unix(1_700_000_000, 0))
+	setForTest(t, &providerBreaker, newCircuitBreaker(2, 30*time.Second))
+	setForTest(t, &fetchRetries, 1)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+"&bypassCache=true", nil))
+		return w
+	}
+
+	down = true
+	for i := 0; i < 2; i++ {
+		if w := get(); w.Code != http.StatusBadGateway {
+			t.Fatalf("failing fetch %d status = %d, want 502", i, w.Code)
+		}
+	}
+	calls := provider.Calls(malibu)
+	w := get()
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("open breaker status = %d (Retry-After %q), want 503 after 30s", w.Code, w.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+		t.Errorf("body = %q, want a JSON error", w.Body)
+	}
+	if got := provider.Calls(malibu); got != calls {
+		t.Errorf("open breaker made %d provider calls, want none", got-calls)
+	}
+
+	// The provider recovers: the probe after the cooldown closes the breaker
+	down = false
+	clock.Advance(30 * time.Second)
+	for i := 0; i < 2; i++ {
+		if w := get(); w.Code != http.StatusOK {
+			t.Fatalf("recovered fetch %d status = %d, want 200: %s", i, w.Code, w.Body)
+		}
+	}
+}
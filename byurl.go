@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// spotIDFromSurflineURL extracts the spot ID from a Surfline web URL. Report
+// and forecast pages end in the ID, as in
+// https://www.surfline.com/surf-report/malibu-first-point/5842041f4e65fad6a7708814,
+// and older links carry it as a spotId query parameter.
+func spotIDFromSurflineURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "surfline.com" && !strings.HasSuffix(host, ".surfline.com") {
+		return "", false
+	}
+
+	if spotID := u.Query().Get("spotId"); validSpotID(spotID) {
+		return spotID, true
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		return "", false
+	}
+	switch segments[0] {
+	case "surf-report", "surf-forecasts", "surf-cams":
+		if spotID := segments[len(segments)-1]; validSpotID(spotID) {
+			return spotID, true
+		}
+	}
+	return "", false
+}
+
+// handleByURL serves the forecast for the spot a Surfline URL points at,
+// accepting the same options as /forecast
+func handleByURL(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	raw := r.URL.Query().Get("url")
+	if raw == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_PARAMETER, "Missing url parameter")
+		return
+	}
+	spotID, ok := spotIDFromSurflineURL(raw)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "No spot ID found in Surfline URL")
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del("url")
+	query.Set("spotId", spotID)
+	forecastReq := r.Clone(r.Context())
+	forecastReq.URL.RawQuery = query.Encode()
+	handleForecast(w, forecastReq)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSpotIDFromSurflineURL(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{"https://www.surfline.com/surf-report/malibu-first-point/" + malibu, malibu, true},
+		{"https://surfline.com/surf-forecasts/malibu/" + malibu + "/", malibu, true},
+		{"  http://www.surfline.com/surf-cams/malibu/" + malibu + "  ", malibu, true},
+		{"https://www.surfline.com/surf-report?spotId=" + malibu, malibu, true},
+		{"https://WWW.SURFLINE.COM/surf-report/malibu/" + malibu, malibu, true},
+		{"https://www.surfline.com/surf-news/malibu/" + malibu, "", false},
+		{"https://www.surfline.com/" + malibu, "", false},
+		{"https://www.surfline.com/surf-report/malibu/nope", "", false},
+		{"https://evilsurfline.com/surf-report/malibu/" + malibu, "", false},
+		{"https://www.example.com/surf-report/malibu/" + malibu, "", false},
+		{"ftp://www.surfline.com/surf-report/malibu/" + malibu, "", false},
+		{"not a url", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, ok := spotIDFromSurflineURL(tt.raw)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("spotIDFromSurflineURL() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHandleByURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     url.Values
+		want      int
+		wantCode  string
+		wantUnits string
+	}{
+		{"report url", url.Values{"url": {"https://www.surfline.com/surf-report/malibu/" + malibu}}, http.StatusOK, "", UNITS_IMPERIAL},
+		{"forecast options pass through", url.Values{"url": {"https://www.surfline.com/surf-report/malibu/" + malibu}, "units": {"metric"}}, http.StatusOK, "", UNITS_METRIC},
+		{"unknown spot", url.Values{"url": {"https://www.surfline.com/surf-report/nowhere/" + unknownSpotID}}, http.StatusNotFound, ERR_UNKNOWN_SPOT, ""},
+		{"missing url", url.Values{}, http.StatusBadRequest, ERR_MISSING_PARAMETER, ""},
+		{"not surfline", url.Values{"url": {"https://www.example.com/surf-report/malibu/" + malibu}}, http.StatusBadRequest, ERR_INVALID_PARAMETER, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, newFakeProvider())
+
+			w := httptest.NewRecorder()
+			handleByURL(w, httptest.NewRequest(http.MethodGet, "/forecast/by-url?"+tt.query.Encode(), nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantCode != "" {
+				if got := errorCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %q, want %q", got, tt.wantCode)
+				}
+				return
+			}
+			var response ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if response.SpotID != malibu || response.Units != tt.wantUnits {
+				t.Errorf("response = %s in %s, want %s in %s", response.SpotID, response.Units, malibu, tt.wantUnits)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type CacheItem struct {
+	Response  ForecastResponse `json:"response"`
+	ExpiresAt int64            `json:"expiresAt"`
+}
+
+// Default bound on cached spots, see CACHE_MAX_ENTRIES
+const DEFAULT_CACHE_MAX_ENTRIES = 500
+
+// forecastCacheStore is an in-memory forecast cache that is safe for
+// concurrent use by multiple handlers. It holds at most capacity entries,
+// evicting the least recently used spot to make room. Reads reorder the
+// recency list, so every access takes the write lock.
+//
+// Expired entries are kept for a further staleGrace seconds so they can be
+// served while a fresh copy is fetched, see Lookup.
+type forecastCacheStore struct {
+	mu         sync.Mutex
+	capacity   int
+	staleGrace int64
+	items      map[string]*list.Element
+	order      *list.List // front is most recently used
+
+	// Lookup outcomes since startup or the last ResetStats
+	hits   uint64
+	misses uint64
+
+	// Channels closed on the next write to a spot, see Watch
+	watchers map[string]chan struct{}
+}
+
+// CacheStats summarizes the cache's contents and how well it is serving
+type CacheStats struct {
+	Entries  int     `json:"entries"`
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hitRatio"`
+
+	// Soonest expiry among the cached entries in unix seconds, zero if empty
+	OldestExpiry int64 `json:"oldestExpiry"`
+}
+
+type cacheEntry struct {
+	spotID string
+	item   CacheItem
+}
+
+func newForecastCacheStore(capacity int, staleGrace int64) *forecastCacheStore {
+	if capacity <= 0 {
+		capacity = DEFAULT_CACHE_MAX_ENTRIES
+	}
+	return &forecastCacheStore{
+		capacity:   capacity,
+		staleGrace: max(staleGrace, 0),
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		watchers:   make(map[string]chan struct{}),
+	}
+}
+
+// Get returns the cached forecast for a spot if present and not yet expired.
+func (c *forecastCacheStore) Get(spotID string) (ForecastResponse, bool) {
+	resp, fresh, _ := c.Lookup(spotID)
+	if !fresh {
+		return ForecastResponse{}, false
+	}
+	return resp, true
+}
+
+// Lookup returns the cached forecast for a spot along with whether it is
+// still fresh. Expired entries are returned with fresh=false while they are
+// within the stale grace window, and dropped once they fall outside it.
+func (c *forecastCacheStore) Lookup(spotID string) (resp ForecastResponse, fresh bool, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[spotID]
+	if !ok {
+		c.misses++
+		return ForecastResponse{}, false, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	now := appClock.Now().Unix()
+	if entry.item.ExpiresAt+c.staleGrace <= now {
+		c.remove(elem)
+		c.misses++
+		return ForecastResponse{}, false, false
+	}
+	c.order.MoveToFront(elem)
+
+	// A stale entry still needs fetching, so it counts as a miss
+	fresh = entry.item.ExpiresAt > now
+	if fresh {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return entry.item.Response, fresh, true
+}
+
+// Stats reports the current entry count and lookup counters
+func (c *forecastCacheStore) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{Entries: len(c.items), Hits: c.hits, Misses: c.misses}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRatio = float64(c.hits) / float64(lookups)
+	}
+	for _, elem := range c.items {
+		expiresAt := elem.Value.(*cacheEntry).item.ExpiresAt
+		if stats.OldestExpiry == 0 || expiresAt < stats.OldestExpiry {
+			stats.OldestExpiry = expiresAt
+		}
+	}
+	return stats
+}
+
+// ResetStats zeroes the hit and miss counters
+func (c *forecastCacheStore) ResetStats() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits, c.misses = 0, 0
+}
+
+// Peek returns the cached forecast for a spot, expired or not, without
+// counting as a lookup or refreshing its recency
+func (c *forecastCacheStore) Peek(spotID string) (ForecastResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[spotID]
+	if !ok {
+		return ForecastResponse{}, false
+	}
+	return elem.Value.(*cacheEntry).item.Response, true
+}
+
+// ExpiresAt returns when a spot's cached forecast stops being fresh, in unix
+// seconds
+func (c *forecastCacheStore) ExpiresAt(spotID string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[spotID]
+	if !ok {
+		return 0, false
+	}
+	return elem.Value.(*cacheEntry).item.ExpiresAt, true
+}
+
+// Watch returns a channel that is closed the next time a forecast is stored
+// for the spot
+func (c *forecastCacheStore) Watch(spotID string) <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := c.watchers[spotID]
+	if !ok {
+		ch = make(chan struct{})
+		c.watchers[spotID] = ch
+	}
+	return ch
+}
+
+// Set stores a forecast for a spot until expiresAt (unix seconds).
+func (c *forecastCacheStore) Set(spotID string, resp ForecastResponse, expiresAt int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(spotID, CacheItem{
+		Response:  resp,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// set inserts or replaces an entry, evicting the least recently used ones
+// beyond capacity. c.mu must be held.
+func (c *forecastCacheStore) set(spotID string, item CacheItem) {
+	if ch, ok := c.watchers[spotID]; ok {
+		close(ch)
+		delete(c.watchers, spotID)
+	}
+
+	if elem, ok := c.items[spotID]; ok {
+		elem.Value.(*cacheEntry).item = item
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[spotID] = c.order.PushFront(&cacheEntry{spotID: spotID, item: item})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry. c.mu must be held.
+func (c *forecastCacheStore) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).spotID)
+}
+
+// Save writes every unexpired entry to path as JSON. The file is replaced
+// atomically so a crash mid-write never leaves a truncated cache behind.
+func (c *forecastCacheStore) Save(path string) error {
+	c.mu.Lock()
+	now := appClock.Now().Unix()
+	items := make(map[string]CacheItem, len(c.items))
+	for spotID, elem := range c.items {
+		if item := elem.Value.(*cacheEntry).item; item.ExpiresAt > now {
+			items[spotID] = item
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces the file at path with data via a temporary file
+// in the same directory, so readers never see a partial write
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load adds the unexpired entries saved at path to the cache and returns how
+// many were loaded. A missing file is not an error.
+func (c *forecastCacheStore) Load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var items map[string]CacheItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Insert the longest-lived entries last so they are the last evicted
+	spotIDs := make([]string, 0, len(items))
+	for spotID := range items {
+		spotIDs = append(spotIDs, spotID)
+	}
+	sort.Slice(spotIDs, func(i, j int) bool {
+		return items[spotIDs[i]].ExpiresAt < items[spotIDs[j]].ExpiresAt
+	})
+
+	now := appClock.Now().Unix()
+	loaded := 0
+	for _, spotID := range spotIDs {
+		if items[spotID].ExpiresAt <= now {
+			continue
+		}
+		c.set(spotID, items[spotID])
+		loaded++
+	}
+	return min(loaded, c.capacity), nil
+}
+
+// Delete evicts a single spot, returning the number of entries removed
+func (c *forecastCacheStore) Delete(spotID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[spotID]
+	if !ok {
+		return 0
+	}
+	c.remove(elem)
+	return 1
+}
+
+// Clear evicts every entry, returning the number removed
+func (c *forecastCacheStore) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evicted := len(c.items)
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	return evicted
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestForecastCacheGet(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name      string
+		expiresAt int64
+		stored    bool
+		wantFound bool
+	}{
+		{"fresh", now + 60, true, true},
+		{"expired", now - 1, true, false},
+		{"never stored", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newForecastCacheStore(DEFAULT_CACHE_MAX_ENTRIES, 0)
+			if tt.stored {
+				cache.Set("spot", ForecastResponse{SpotID: "spot"}, tt.expiresAt)
+			}
+			resp, found := cache.Get("spot")
+			if found != tt.wantFound {
+				t.Fatalf("Get() found = %v, want %v", found, tt.wantFound)
+			}
+			if found && resp.SpotID != "spot" {
+				t.Errorf("Get() SpotID = %q, want %q", resp.SpotID, "spot")
+			}
+		})
+	}
+}
+
+func TestForecastCacheLookupExpiry(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name      string
+		ttl       int64
+		grace     int64
+		advance   time.Duration
+		wantFresh bool
+		wantFound bool
+	}{
+		{"fresh before expiry", 60, 0, 59 * time.Second, true, true},
+		{"expired at ttl", 60, 0, 60 * time.Second, false, false},
+		{"stale within grace", 60, 30, 75 * time.Second, false, true},
+		{"dropped after grace", 60, 30, 90 * time.Second, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := useFakeClock(t, start)
+			cache := newForecastCacheStore(10, tt.grace)
+			cache.Set("spot", ForecastResponse{SpotID: "spot"}, start.Unix()+tt.ttl)
+
+			clock.Advance(tt.advance)
+			resp, fresh, found := cache.Lookup("spot")
+			if fresh != tt.wantFresh || found != tt.wantFound {
+				t.Fatalf("Lookup() fresh=%v found=%v, want fresh=%v found=%v", fresh, found, tt.wantFresh, tt.wantFound)
+			}
+			if found && resp.SpotID != "spot" {
+				t.Errorf("Lookup() SpotID = %q, want %q", resp.SpotID, "spot")
+			}
+		})
+	}
+}
+
+func TestForecastCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	useFakeClock(t, start)
+	cache := newForecastCacheStore(2, 0)
+	expiresAt := start.Unix() + 60
+
+	cache.Set("a", ForecastResponse{SpotID: "a"}, expiresAt)
+	cache.Set("b", ForecastResponse{SpotID: "b"}, expiresAt)
+	cache.Get("a")
+	cache.Set("c", ForecastResponse{SpotID: "c"}, expiresAt)
+
+	for spotID, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.Get(spotID); ok != want {
+			t.Errorf("Get(%q) found = %v, want %v", spotID, ok, want)
+		}
+	}
+}
+
+func TestForecastCacheDropsExpiredOnGet(t *testing.T) {
+	cache := newForecastCacheStore(2, 0)
+	cache.Set("a", ForecastResponse{SpotID: "a"}, time.Now().Unix()-1)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("Get() found an expired entry")
+	}
+	if len(cache.items) != 0 || cache.order.Len() != 0 {
+		t.Errorf("expired entry was kept: %d items, %d in order", len(cache.items), cache.order.Len())
+	}
+}
+
+func TestForecastCacheSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	now := time.Now().Unix()
+	saved := newForecastCacheStore(DEFAULT_CACHE_MAX_ENTRIES, 0)
+	saved.Set(malibu, fakeForecast(malibu, 7.5), now+600)
+	saved.Set(huntington, fakeForecast(huntington, 2), now-1)
+	if err := saved.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A restarted server loads the file into its fresh cache
+	provider := newFakeProvider()
+	useProvider(t, provider)
+	loaded, err := forecastCache.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded != 1 {
+		t.Errorf("Load() = %d entries, want only the unexpired one", loaded)
+	}
+
+	w := httptest.NewRecorder()
+	handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var response ForecastResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	if response.WaveHeightFt != 7.5 || provider.Calls(malibu) != 0 {
+		t.Errorf("WaveHeightFt = %v after %d fetches, want the loaded 7.5 and no fetch", response.WaveHeightFt, provider.Calls(malibu))
+	}
+	if _, ok := forecastCache.Get(huntington); ok {
+		t.Error("expired entry was loaded")
+	}
+}
+
+func TestForecastCacheLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"missing file", filepath.Join(dir, "missing.json"), false},
+		{"corrupt file", corrupt, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := newForecastCacheStore(DEFAULT_CACHE_MAX_ENTRIES, 0).Load(tt.path)
+			if (err != nil) != tt.wantErr || loaded != 0 {
+				t.Errorf("Load() = %d, %v, want 0 entries and error %v", loaded, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// clock tells the time. Forecast timestamps, cache expiry, the circuit breaker
+// and the rate limiter read it through appClock rather than calling time.Now,
+// so tests can pin it with a fakeClock.
+type clock interface {
+	Now() time.Time
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// The clock the server runs on
+var appClock clock = realClock{}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock stands still until it is advanced, for deterministic tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useFakeClock swaps appClock for a fakeClock at now for the rest of the test
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	t.Helper()
+	clock := newFakeClock(now)
+	previous := appClock
+	appClock = clock
+	t.Cleanup(func() { appClock = previous })
+	return clock
+}
+
+func TestFakeClockAdvance(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		advance []time.Duration
+		want    time.Time
+	}{
+		{"stands still", nil, start},
+		{"single step", []time.Duration{time.Minute}, start.Add(time.Minute)},
+		{"accumulates", []time.Duration{time.Second, 2 * time.Hour}, start.Add(2*time.Hour + time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock(start)
+			for _, d := range tt.advance {
+				clock.Advance(d)
+			}
+			if got := clock.Now(); !got.Equal(tt.want) {
+				t.Errorf("Now() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
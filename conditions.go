@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseWaveHeight extracts the numeric values from a wave height string,
+// given either as a single height such as "3.8 ft at 12 seconds 215 degrees"
+// or as a range such as "3-4.5 ft at 12 seconds 215 degrees". A single height
+// is returned as both the minimum and the maximum.
+func parseWaveHeight(s string) (minFt, maxFt float64, periodSec, directionDeg int, err error) {
+	var height string
+	n, err := fmt.Sscanf(s, "%s ft at %d seconds %d degrees", &height, &periodSec, &directionDeg)
+	if err != nil || n != 3 {
+		return 0, 0, 0, 0, fmt.Errorf("malformed wave height %q", s)
+	}
+
+	rawMin, rawMax, isRange := strings.Cut(height, "-")
+	if !isRange {
+		rawMax = rawMin
+	}
+	minFt, minErr := strconv.ParseFloat(rawMin, 64)
+	maxFt, maxErr := strconv.ParseFloat(rawMax, 64)
+	if minErr != nil || maxErr != nil || minFt < 0 || minFt > maxFt {
+		return 0, 0, 0, 0, fmt.Errorf("malformed wave height %q", s)
+	}
+	return minFt, maxFt, periodSec, directionDeg, nil
+}
+
+// formatWaveHeight renders the display string parseWaveHeight reads, as a
+// single height when the range is empty
+func formatWaveHeight(minFt, maxFt float64, periodSec, directionDeg int) string {
+	if minFt == maxFt {
+		return fmt.Sprintf("%.1f ft at %d seconds %d degrees", minFt, periodSec, directionDeg)
+	}
+	return fmt.Sprintf("%g-%g ft at %d seconds %d degrees", minFt, maxFt, periodSec, directionDeg)
+}
+
+// parseWindSpeed extracts the speed from a wind string such as "5 mph"
+func parseWindSpeed(s string) (float64, error) {
+	var mph float64
+	n, err := fmt.Sscanf(s, "%g mph", &mph)
+	if err != nil || n != 1 {
+		return 0, fmt.Errorf("malformed wind speed %q", s)
+	}
+	return mph, nil
+}
+
+// Surf quality ratings, from worst to best
+const (
+	RATING_POOR = "poor"
+	RATING_FAIR = "fair"
+	RATING_GOOD = "good"
+	RATING_EPIC = "epic"
+)
+
+// rateConditions scores conditions from 0 to 100 and buckets the score into a
+// rating. Up to 40 points come from wave height, 30 from swell period and 30
+// from the wind: light offshore wind scores best, onshore wind costs points
+// the stronger it blows, and more again for gusts above the sustained speed.
+func rateConditions(waveFt float64, periodSec int, windMph, gustMph float64, windDir string) (int, string) {
+	// Nothing to ride no matter what the wind does
+	if waveFt <= 0 {
+		return 0, RATING_POOR
+	}
+
+	waveScore := math.Min(waveFt/6, 1) * 40
+	if waveFt > 12 {
+		// Big enough to be out of reach for most surfers
+		waveScore -= math.Min(waveFt-12, 10) * 2
+	}
+
+	periodScore := math.Min(float64(periodSec)/16, 1) * 30
+
+	var windScore float64
+	switch dir := strings.ToLower(windDir); {
+	case strings.Contains(dir, "offshore"):
+		windScore = 30
+		if windMph > 15 {
+			windScore = 20
+		}
+	case strings.Contains(dir, "cross"):
+		windScore = 15 - windMph*0.5
+	case strings.Contains(dir, "onshore"):
+		windScore = 10 - windMph - math.Max(gustMph-windMph, 0)*0.5
+	default:
+		windScore = 10
+	}
+
+	score := int(math.Round(waveScore + periodScore + windScore))
+	score = max(0, min(100, score))
+
+	switch {
+	case score >= 75:
+		return score, RATING_EPIC
+	case score >= 55:
+		return score, RATING_GOOD
+	case score >= 30:
+		return score, RATING_FAIR
+	default:
+		return score, RATING_POOR
+	}
+}
+
+// Seawater density in kg/m³ and gravitational acceleration in m/s²
+const (
+	SEAWATER_DENSITY = 1025.0
+	GRAVITY          = 9.81
+)
+
+// computeEnergy returns the deepwater wave power of a swell, ρg²H²T/(64π):
+// the energy it carries through each metre of wave crest per second, in kJ.
+// It grows with the square of the height but only linearly with the period.
+func computeEnergy(heightFt float64, periodSec int) float64 {
+	heightM := ftToM(heightFt)
+	return SEAWATER_DENSITY * GRAVITY * GRAVITY * heightM * heightM * float64(periodSec) / (64 * math.Pi) / 1000
+}
+
+// compassPoints are the 16 compass directions, clockwise from north
+var compassPoints = [16]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// degToCompass maps a bearing in degrees to the nearest of the 16 compass
+// points. Each point covers 22.5 degrees centred on its bearing, and values
+// outside 0-359 wrap around.
+func degToCompass(deg int) string {
+	normalized := math.Mod(float64(deg), 360)
+	if normalized < 0 {
+		normalized += 360
+	}
+	index := int(math.Floor(normalized/22.5+0.5)) % len(compassPoints)
+	return compassPoints[index]
+}
+
+// Relative wind directions
+const (
+	WIND_OFFSHORE    = "offshore"
+	WIND_ONSHORE     = "onshore"
+	WIND_CROSS_SHORE = "cross-shore"
+)
+
+// classifyWind compares the bearing the wind blows from with the bearing the
+// beach faces. Wind from the sea (within 45 degrees of the beach's facing) is
+// onshore, wind from the land (within 45 degrees of the opposite bearing) is
+// offshore, and anything in between is cross-shore.
+func classifyWind(windDeg, beachFacingDeg int) string {
+	diff := math.Abs(math.Mod(float64(windDeg-beachFacingDeg), 360))
+	if diff > 180 {
+		diff = 360 - diff
+	}
+
+	switch {
+	case diff <= 45:
+		return WIND_ONSHORE
+	case diff >= 135:
+		return WIND_OFFSHORE
+	default:
+		return WIND_CROSS_SHORE
+	}
+}
+
+// enrichForecast fills in the fields derived from an imperial forecast's raw
+// conditions
+func enrichForecast(resp *ForecastResponse) {
+	resp.ApiVersion = API_VERSION
+	// Unknown wind leaves the speed at zero
+	resp.WindSpeedMph, _ = parseWindSpeed(resp.WindSpeed)
+	resp.UVCategory = uvCategory(resp.UVIndex)
+	resp.Score, resp.Rating = rateConditions(resp.WaveHeightFt, resp.SwellPeriodSec, resp.WindSpeedMph, resp.WindGustMph, resp.WindDirection)
+
+	// Spots without swell data have no direction or energy to describe
+	if resp.WaveHeightFt > 0 {
+		resp.SwellCompass = degToCompass(resp.SwellDirectionDeg)
+		resp.EnergyKJ = math.Round(computeEnergy(resp.WaveHeightFt, resp.SwellPeriodSec)*10) / 10
+	}
+
+	spot, ok := knownSpots.Get(resp.SpotID)
+	if !ok {
+		return
+	}
+	if resp.WindSpeed != "Unknown" {
+		resp.WindRelative = classifyWind(resp.WindDegrees, spot.BeachFacingDeg)
+	}
+	loc := spotTimezone(spot)
+	for i, event := range resp.TideEvents {
+		resp.TideEvents[i].LocalTime = time.Unix(event.Time, 0).In(loc).Format(time.RFC3339)
+	}
+	resp.CrowdLevel = estimateCrowd(spot.Popularity, time.Unix(resp.Timestamp, 0).In(loc))
+	if sunrise, sunset, ok := sunTimes(spot.Lat, spot.Lon, time.Unix(resp.Timestamp, 0)); ok {
+		resp.Sunrise = sunrise.Unix()
+		resp.Sunset = sunset.Unix()
+	}
+}
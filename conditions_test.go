@@ -0,0 +1,271 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseWaveHeight(t *testing.T) {
+	tests := []struct {
+		name          string
+		s             string
+		wantMin       float64
+		wantMax       float64
+		wantPeriod    int
+		wantDirection int
+		wantErr       bool
+	}{
+		{"malibu", "3.8 ft at 12 seconds 215 degrees", 3.8, 3.8, 12, 215, false},
+		{"huntington", "2.5 ft at 10 seconds 220 degrees", 2.5, 2.5, 10, 220, false},
+		{"tamarindo", "4.5 ft at 14 seconds 210 degrees", 4.5, 4.5, 14, 210, false},
+		{"jaco", "3.7 ft at 12 seconds 205 degrees", 3.7, 3.7, 12, 205, false},
+		{"dominical", "5.2 ft at 16 seconds 207 degrees", 5.2, 5.2, 16, 207, false},
+		{"range", "3-4.5 ft at 12 seconds 215 degrees", 3, 4.5, 12, 215, false},
+		{"unknown", "Unknown", 0, 0, 0, 0, true},
+		{"reversed range", "5-3 ft at 12 seconds 215 degrees", 0, 0, 0, 0, true},
+		{"negative height", "-1 ft at 12 seconds 215 degrees", 0, 0, 0, 0, true},
+		{"missing direction", "3 ft at 12 seconds", 0, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minFt, maxFt, period, direction, err := parseWaveHeight(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWaveHeight(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if minFt != tt.wantMin || maxFt != tt.wantMax || period != tt.wantPeriod || direction != tt.wantDirection {
+				t.Errorf("parseWaveHeight(%q) = %v, %v, %v, %v", tt.s, minFt, maxFt, period, direction)
+			}
+		})
+	}
+}
+
+func TestFormatWaveHeightRoundTrip(t *testing.T) {
+	tests := []struct {
+		minFt, maxFt float64
+		want         string
+	}{
+		{3, 4.5, "3-4.5 ft at 12 seconds 215 degrees"},
+		{4.2, 4.2, "4.2 ft at 12 seconds 215 degrees"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := formatWaveHeight(tt.minFt, tt.maxFt, 12, 215)
+			if got != tt.want {
+				t.Fatalf("formatWaveHeight() = %q, want %q", got, tt.want)
+			}
+			if minFt, maxFt, _, _, err := parseWaveHeight(got); err != nil || minFt != tt.minFt || maxFt != tt.maxFt {
+				t.Errorf("parseWaveHeight(%q) = %v, %v, %v", got, minFt, maxFt, err)
+			}
+		})
+	}
+}
+
+func TestMockForecastParsedFields(t *testing.T) {
+	for _, spot := range defaultSpots {
+		spotID := spot.SpotID
+		response := getMockForecastResponse(spotID)
+		if response.WaveHeightFt <= 0 || response.SwellPeriodSec <= 0 || response.SwellDirectionDeg <= 0 {
+			t.Errorf("%s parsed fields = %v, %v, %v", spotID, response.WaveHeightFt, response.SwellPeriodSec, response.SwellDirectionDeg)
+		}
+		if response.WaveHeightMinFt > response.WaveHeightFt || response.WaveHeightFt > response.WaveHeightMaxFt {
+			t.Errorf("%s height %v is outside its range %v-%v", spotID, response.WaveHeightFt, response.WaveHeightMinFt, response.WaveHeightMaxFt)
+		}
+	}
+}
+
+func TestRateConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		waveFt     float64
+		periodSec  int
+		windMph    float64
+		gustMph    float64
+		windDir    string
+		wantScore  int
+		wantRating string
+	}{
+		{"flat", 0, 16, 0, 0, "Offshore", 0, RATING_POOR},
+		{"perfect", 6, 16, 5, 5, "Offshore", 100, RATING_EPIC},
+		{"strong offshore", 6, 16, 20, 20, "Offshore", 90, RATING_EPIC},
+		{"too big", 15, 16, 5, 5, "Offshore", 94, RATING_EPIC},
+		{"cross-shore", 3, 12, 5, 5, "Cross-shore", 55, RATING_GOOD},
+		{"unknown wind", 3, 12, 0, 0, "Unknown", 53, RATING_FAIR},
+		{"gusty onshore", 2, 6, 15, 25, "Onshore", 15, RATING_POOR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, rating := rateConditions(tt.waveFt, tt.periodSec, tt.windMph, tt.gustMph, tt.windDir)
+			if score != tt.wantScore || rating != tt.wantRating {
+				t.Errorf("rateConditions() = %d %s, want %d %s", score, rating, tt.wantScore, tt.wantRating)
+			}
+		})
+	}
+}
+
+func TestComputeEnergy(t *testing.T) {
+	base := computeEnergy(3, 10)
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"no waves", computeEnergy(0, 10), 0},
+		{"double the height", computeEnergy(6, 10), 4 * base},
+		{"double the period", computeEnergy(3, 20), 2 * base},
+		{"one metre at ten seconds", computeEnergy(1/0.3048, 10), 1025 * 9.81 * 9.81 * 10 / (64 * math.Pi) / 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if math.Abs(tt.got-tt.want) > 1e-9 {
+				t.Errorf("computeEnergy() = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnrichForecastEnergy(t *testing.T) {
+	// Equal heights, but the longer period carries more energy
+	short := fakeForecast(malibu, 4)
+	short.SwellPeriodSec = 8
+	long := fakeForecast(huntington, 4)
+	long.SwellPeriodSec = 16
+	flat := fakeForecast(tamarindo, 0)
+	for _, resp := range []*ForecastResponse{&short, &long, &flat} {
+		enrichForecast(resp)
+	}
+
+	if short.EnergyKJ <= 0 || long.EnergyKJ <= short.EnergyKJ {
+		t.Errorf("EnergyKJ = %v at 8s and %v at 16s, want the longer period higher", short.EnergyKJ, long.EnergyKJ)
+	}
+	if flat.EnergyKJ != 0 {
+		t.Errorf("EnergyKJ without waves = %v, want 0", flat.EnergyKJ)
+	}
+}
+
+func TestParseWindSpeed(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    float64
+		wantErr bool
+	}{
+		{"5 mph", 5, false},
+		{"12.5 mph", 12.5, false},
+		{"Unknown", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseWindSpeed(tt.s)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseWindSpeed(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+			}
+		})
+	}
+
+	// Every mock spot's wind must parse
+	for _, spot := range defaultSpots {
+		windSpeed := getMockForecastResponse(spot.SpotID).WindSpeed
+		if mph, err := parseWindSpeed(windSpeed); err != nil || mph <= 0 {
+			t.Errorf("%s: parseWindSpeed(%q) = %v, %v", spot.Location, windSpeed, mph, err)
+		}
+	}
+}
+
+func TestDegToCompass(t *testing.T) {
+	tests := []struct {
+		deg  int
+		want string
+	}{
+		{0, "N"},
+		{45, "NE"},
+		{215, "SW"},
+		{11, "N"},
+		{12, "NNE"},
+		{180, "S"},
+		{210, "SSW"},
+		{225, "SW"},
+		{348, "NNW"},
+		{359, "N"},
+		{360, "N"},
+		{-90, "W"},
+	}
+	for _, tt := range tests {
+		if got := degToCompass(tt.deg); got != tt.want {
+			t.Errorf("degToCompass(%d) = %q, want %q", tt.deg, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyWind(t *testing.T) {
+	tests := []struct {
+		name        string
+		windDeg     int
+		beachFacing int
+		want        string
+	}{
+		{"straight in", 190, 190, WIND_ONSHORE},
+		{"edge of onshore", 235, 190, WIND_ONSHORE},
+		{"just cross", 236, 190, WIND_CROSS_SHORE},
+		{"straight out", 10, 190, WIND_OFFSHORE},
+		{"across north", 350, 10, WIND_ONSHORE},
+		{"side on", 100, 190, WIND_CROSS_SHORE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyWind(tt.windDeg, tt.beachFacing); got != tt.want {
+				t.Errorf("classifyWind(%d, %d) = %q, want %q", tt.windDeg, tt.beachFacing, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnrichForecastWindRelative(t *testing.T) {
+	tests := []struct {
+		spotID string
+		want   string
+	}{
+		{malibu, WIND_OFFSHORE},
+		{unknownSpotID, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spotID, func(t *testing.T) {
+			resp := getMockForecastResponse(tt.spotID)
+			enrichForecast(&resp)
+			if resp.WindRelative != tt.want {
+				t.Errorf("WindRelative = %q, want %q", resp.WindRelative, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnrichForecastWindSpeed(t *testing.T) {
+	resp := getMockForecastResponse(malibu)
+	enrichForecast(&resp)
+	if resp.WindSpeedMph != 5 || resp.WindSpeed != "5 mph" {
+		t.Errorf("WindSpeedMph, WindSpeed = %v, %q, want 5 and the original string", resp.WindSpeedMph, resp.WindSpeed)
+	}
+}
+
+func TestEnrichForecastTideLocalTime(t *testing.T) {
+	// Costa Rica keeps UTC-6 all year
+	at := time.Date(2024, time.July, 1, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		spotID string
+		want   string
+	}{
+		{tamarindo, "2024-07-01T12:30:00-06:00"},
+		{malibu, "2024-07-01T11:30:00-07:00"},
+		{unknownSpotID, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spotID, func(t *testing.T) {
+			resp := getMockForecastResponse(tt.spotID)
+			resp.TideEvents = []TideEvent{{Type: TIDE_HIGH, Time: at.Unix()}}
+			enrichForecast(&resp)
+			if got := resp.TideEvents[0].LocalTime; got != tt.want {
+				t.Errorf("LocalTime = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"log/slog"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runtime configuration, populated from the environment by loadConfig
+var (
+	cacheDuration           int64 = CACHE_DURATION
+	rateLimitPerMin               = DEFAULT_RATE_LIMIT_PER_MIN
+	allowedOrigins                = []string{"*"}
+	cacheFile               string
+	adminToken              string
+	fetchTimeout            = DEFAULT_FETCH_TIMEOUT_MS * time.Millisecond
+	cacheMaxEntries         = DEFAULT_CACHE_MAX_ENTRIES
+	refreshInterval         time.Duration
+	prefetchSpots           []string
+	staleGrace              int64
+	historySize             = DEFAULT_HISTORY_SIZE
+	favoritesFile           = DEFAULT_FAVORITES_FILE
+	cacheTTLOverrides       map[string]int64
+	trendThresholdFt              = DEFAULT_TREND_THRESHOLD_FT
+	fetchRetries                  = DEFAULT_FETCH_RETRIES
+	slowRequestThreshold          = DEFAULT_SLOW_REQUEST_MS * time.Millisecond
+	maxBodyBytes            int64 = DEFAULT_MAX_BODY_BYTES
+	maxQueryLength                = DEFAULT_MAX_QUERY_LENGTH
+	apiKeyRequired          bool
+	apiKeys                 []string
+	breakerThreshold        = DEFAULT_CIRCUIT_BREAKER_THRESHOLD
+	breakerCooldown         = DEFAULT_CIRCUIT_BREAKER_COOLDOWN_SECONDS * time.Second
+	surflineURL             = surflineBaseURL
+	readHeaderTimeout       = DEFAULT_READ_HEADER_TIMEOUT_SECONDS * time.Second
+	readTimeout             = DEFAULT_READ_TIMEOUT_SECONDS * time.Second
+	writeTimeout            = DEFAULT_WRITE_TIMEOUT_SECONDS * time.Second
+	idleTimeout             = DEFAULT_IDLE_TIMEOUT_SECONDS * time.Second
+	maxBatchSize            = DEFAULT_MAX_BATCH_SIZE
+	defaultSpotID           string
+	jsonFieldStyle          = FIELD_STYLE_CAMEL
+	disabledSpots           map[string]bool
+	upstreamTimeout         = DEFAULT_UPSTREAM_TIMEOUT_SECONDS * time.Second
+	upstreamIdleConnTimeout = DEFAULT_UPSTREAM_IDLE_CONN_TIMEOUT_SECONDS * time.Second
+	alertCheckInterval      = DEFAULT_ALERT_CHECK_INTERVAL_SECONDS * time.Second
+	trustedProxies          []netip.Prefix
+	negativeCacheTTL        int64 = DEFAULT_NEGATIVE_CACHE_SECONDS
+	attributionText               = DEFAULT_ATTRIBUTION_TEXT
+)
+
+// loadConfig reads optional settings from the environment. Missing or
+// invalid values fall back to their defaults.
+func loadConfig() {
+	level, err := parseLogLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		slog.Warn("invalid config value, using default", "event", "config_invalid", "name", "LOG_LEVEL", "value", os.Getenv("LOG_LEVEL"), "default", "info")
+	}
+	logLevel.Set(level)
+
+	cacheDuration = int64(envInt("CACHE_DURATION_SECONDS", CACHE_DURATION))
+	rateLimitPerMin = envInt("RATE_LIMIT_PER_MIN", DEFAULT_RATE_LIMIT_PER_MIN)
+	allowedOrigins = envList("ALLOWED_ORIGINS", []string{"*"})
+	cacheFile = os.Getenv("CACHE_FILE")
+	adminToken = os.Getenv("ADMIN_TOKEN")
+	fetchTimeout = time.Duration(envInt("FETCH_TIMEOUT_MS", DEFAULT_FETCH_TIMEOUT_MS)) * time.Millisecond
+	cacheMaxEntries = envInt("CACHE_MAX_ENTRIES", DEFAULT_CACHE_MAX_ENTRIES)
+	refreshInterval = time.Duration(envInt("REFRESH_INTERVAL_SECONDS", 0)) * time.Second
+	prefetchSpots = envList("PREFETCH_SPOTS", nil)
+	staleGrace = int64(envInt("STALE_GRACE_SECONDS", 0))
+	historySize = envInt("HISTORY_SIZE", DEFAULT_HISTORY_SIZE)
+	favoritesFile = envString("FAVORITES_FILE", DEFAULT_FAVORITES_FILE)
+	cacheTTLOverrides = parseTTLOverrides(os.Getenv("CACHE_TTL_OVERRIDES"))
+	trendThresholdFt = envFloat("TREND_THRESHOLD_FT", DEFAULT_TREND_THRESHOLD_FT)
+	fetchRetries = envInt("FETCH_RETRIES", DEFAULT_FETCH_RETRIES)
+	slowRequestThreshold = time.Duration(envInt("SLOW_REQUEST_MS", DEFAULT_SLOW_REQUEST_MS)) * time.Millisecond
+	maxBodyBytes = int64(envInt("MAX_BODY_BYTES", DEFAULT_MAX_BODY_BYTES))
+	maxQueryLength = envInt("MAX_QUERY_LENGTH", DEFAULT_MAX_QUERY_LENGTH)
+	apiKeyRequired = envBool("REQUIRE_API_KEY", false)
+	apiKeys = envList("API_KEYS", nil)
+	breakerThreshold = envInt("CIRCUIT_BREAKER_THRESHOLD", DEFAULT_CIRCUIT_BREAKER_THRESHOLD)
+	breakerCooldown = time.Duration(envInt("CIRCUIT_BREAKER_COOLDOWN_SECONDS", DEFAULT_CIRCUIT_BREAKER_COOLDOWN_SECONDS)) * time.Second
+	surflineURL = envString("SURFLINE_BASE_URL", surflineBaseURL)
+	readHeaderTimeout = time.Duration(envInt("READ_HEADER_TIMEOUT_SECONDS", DEFAULT_READ_HEADER_TIMEOUT_SECONDS)) * time.Second
+	readTimeout = time.Duration(envInt("READ_TIMEOUT_SECONDS", DEFAULT_READ_TIMEOUT_SECONDS)) * time.Second
+	writeTimeout = time.Duration(envInt("WRITE_TIMEOUT_SECONDS", DEFAULT_WRITE_TIMEOUT_SECONDS)) * time.Second
+	idleTimeout = time.Duration(envInt("IDLE_TIMEOUT_SECONDS", DEFAULT_IDLE_TIMEOUT_SECONDS)) * time.Second
+	maxBatchSize = envInt("MAX_BATCH_SIZE", DEFAULT_MAX_BATCH_SIZE)
+	defaultSpotID = os.Getenv("DEFAULT_SPOT_ID")
+	jsonFieldStyle = parseFieldStyle(os.Getenv("JSON_FIELD_STYLE"))
+	disabledSpots = envSet("DISABLED_SPOTS")
+	upstreamTimeout = time.Duration(envInt("UPSTREAM_TIMEOUT_SECONDS", DEFAULT_UPSTREAM_TIMEOUT_SECONDS)) * time.Second
+	upstreamIdleConnTimeout = time.Duration(envInt("UPSTREAM_IDLE_CONN_TIMEOUT_SECONDS", DEFAULT_UPSTREAM_IDLE_CONN_TIMEOUT_SECONDS)) * time.Second
+	alertCheckInterval = time.Duration(envInt("ALERT_CHECK_INTERVAL_SECONDS", DEFAULT_ALERT_CHECK_INTERVAL_SECONDS)) * time.Second
+	trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	negativeCacheTTL = int64(envInt("NEGATIVE_CACHE_SECONDS", DEFAULT_NEGATIVE_CACHE_SECONDS))
+	attributionText = envString("ATTRIBUTION_TEXT", DEFAULT_ATTRIBUTION_TEXT)
+}
+
+// listenAddr returns the address to bind. LISTEN_ADDR (e.g. 127.0.0.1:8080)
+// takes precedence; otherwise the server listens on every interface at PORT,
+// defaulting to 8080.
+func listenAddr() string {
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		return addr
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return ":" + port
+}
+
+// parseTTLOverrides parses per-spot cache durations in seconds written as
+// "spotA=600,spotB=3600". Malformed entries are logged and skipped.
+func parseTTLOverrides(raw string) map[string]int64 {
+	overrides := make(map[string]int64)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		spotID, rawTTL, _ := strings.Cut(entry, "=")
+		ttl, err := strconv.ParseInt(strings.TrimSpace(rawTTL), 10, 64)
+		if err != nil || ttl <= 0 || strings.TrimSpace(spotID) == "" {
+			slog.Warn("ignoring invalid cache TTL override", "event", "config_invalid", "name", "CACHE_TTL_OVERRIDES", "value", entry)
+			continue
+		}
+		overrides[strings.TrimSpace(spotID)] = ttl
+	}
+	return overrides
+}
+
+// cacheTTL returns how long a spot's forecast is cached for, in seconds
+func cacheTTL(spotID string) int64 {
+	if ttl, ok := cacheTTLOverrides[spotID]; ok {
+		return ttl
+	}
+	return cacheDuration
+}
+
+// envString returns an environment variable, or def if it is unset
+func envString(name, def string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return def
+}
+
+// envInt parses an integer environment variable, returning def if it is
+// unset or not a valid integer.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "event", "config_invalid", "name", name, "value", raw, "default", def)
+		return def
+	}
+	return value
+}
+
+// envFloat parses a decimal environment variable, returning def if it is
+// unset or not a valid number.
+func envFloat(name string, def float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "event", "config_invalid", "name", name, "value", raw, "default", def)
+		return def
+	}
+	return value
+}
+
+// envBool parses a boolean environment variable, returning def if it is
+// unset or not a valid boolean.
+func envBool(name string, def bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "event", "config_invalid", "name", name, "value", raw, "default", def)
+		return def
+	}
+	return value
+}
+
+// envList parses a comma-separated environment variable, returning def if it
+// is unset or contains no entries.
+func envList(name string, def []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return def
+	}
+	return values
+}
+
+// parseFieldStyle reads JSON_FIELD_STYLE, falling back to camelCase when it
+// is unset or unrecognised
+func parseFieldStyle(raw string) string {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", FIELD_STYLE_CAMEL:
+		return FIELD_STYLE_CAMEL
+	case FIELD_STYLE_SNAKE:
+		return FIELD_STYLE_SNAKE
+	}
+	slog.Warn("invalid config value, using default", "event", "config_invalid", "name", "JSON_FIELD_STYLE", "value", raw, "default", FIELD_STYLE_CAMEL)
+	return FIELD_STYLE_CAMEL
+}
+
+// envSet parses a comma-separated environment variable into a set
+func envSet(name string) map[string]bool {
+	set := make(map[string]bool)
+	for _, value := range envList(name, nil) {
+		set[value] = true
+	}
+	return set
+}
+
+// parseTrustedProxies parses the proxies allowed to set X-Forwarded-For,
+// written as addresses or CIDR ranges: "10.0.0.0/8,192.168.1.5". Malformed
+// entries are logged and skipped.
+func parseTrustedProxies(raw string) []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			slog.Warn("ignoring invalid trusted proxy", "event", "config_invalid", "name", "TRUSTED_PROXIES", "value", entry)
+			continue
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies
+}
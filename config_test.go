@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int
+	}{
+		{"unset", "", 7},
+		{"set", "42", 42},
+		{"negative", "-1", -1},
+		{"invalid", "forty", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SURF_TEST_INT", tt.raw)
+			if got := envInt("SURF_TEST_INT", 7); got != tt.want {
+				t.Errorf("envInt() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvFloat(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want float64
+	}{
+		{"unset", "", 0.5},
+		{"set", "1.25", 1.25},
+		{"invalid", "half", 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SURF_TEST_FLOAT", tt.raw)
+			if got := envFloat("SURF_TEST_FLOAT", 0.5); got != tt.want {
+				t.Errorf("envFloat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheDurationFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int64
+	}{
+		{"configured", "60", 60},
+		{"unset", "", CACHE_DURATION},
+		{"invalid", "soon", CACHE_DURATION},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CACHE_DURATION_SECONDS", tt.raw)
+			setForTest(t, &cacheDuration, cacheDuration)
+			cache := useCache(t)
+			loadConfig()
+
+			before := time.Now().Unix()
+			getForecast(context.Background(), malibu, false)
+			after := time.Now().Unix()
+
+			expiresAt := cache.items[malibu].Value.(*cacheEntry).item.ExpiresAt
+			if expiresAt < before+tt.want || expiresAt > after+tt.want {
+				t.Errorf("ExpiresAt = %d, want %d seconds from now", expiresAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTTLOverrides(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want map[string]int64
+	}{
+		{"empty", "", map[string]int64{}},
+		{"two spots", malibu + "=600, " + huntington + " = 3600", map[string]int64{malibu: 600, huntington: 3600}},
+		{"malformed entries are skipped", malibu + "=600,nope,=60," + huntington + "=0," + tamarindo + "=soon", map[string]int64{malibu: 600}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTTLOverrides(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTTLOverrides(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	setForTest(t, &cacheDuration, 1800)
+	setForTest(t, &cacheTTLOverrides, map[string]int64{malibu: 600})
+
+	tests := []struct {
+		spotID string
+		want   int64
+	}{
+		{malibu, 600},
+		{huntington, 1800},
+	}
+	for _, tt := range tests {
+		if got := cacheTTL(tt.spotID); got != tt.want {
+			t.Errorf("cacheTTL(%s) = %d, want %d", tt.spotID, got, tt.want)
+		}
+	}
+}
+
+// An overridden spot expires on its own schedule while others keep the
+// global duration
+func TestCacheTTLOverrideExpiry(t *testing.T) {
+	setForTest(t, &cacheDuration, 1800)
+	setForTest(t, &cacheTTLOverrides, parseTTLOverrides(malibu+"=600"))
+	cache := useCache(t)
+
+	before := time.Now().Unix()
+	for _, spotID := range []string{malibu, huntington} {
+		if _, err := getForecast(context.Background(), spotID, false); err != nil {
+			t.Fatalf("getForecast(%s) error = %v", spotID, err)
+		}
+	}
+	after := time.Now().Unix()
+
+	for spotID, ttl := range map[string]int64{malibu: 600, huntington: 1800} {
+		expiresAt := cache.items[spotID].Value.(*cacheEntry).item.ExpiresAt
+		if expiresAt < before+ttl || expiresAt > after+ttl {
+			t.Errorf("%s ExpiresAt = %d, want %d seconds from now", spotID, expiresAt, ttl)
+		}
+	}
+}
+
+func TestEnvList(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"unset", "", []string{"*"}},
+		{"one", "https://example.com", []string{"https://example.com"}},
+		{"several, spaced", " a , b,,c ", []string{"a", "b", "c"}},
+		{"only separators", " , ,", []string{"*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SURF_TEST_LIST", tt.raw)
+			if got := envList("SURF_TEST_LIST", []string{"*"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldStyle(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"", FIELD_STYLE_CAMEL},
+		{"camel", FIELD_STYLE_CAMEL},
+		{" SNAKE ", FIELD_STYLE_SNAKE},
+		{"kebab", FIELD_STYLE_CAMEL},
+	}
+	for _, tt := range tests {
+		if got := parseFieldStyle(tt.raw); got != tt.want {
+			t.Errorf("parseFieldStyle(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		name       string
+		listenAddr string
+		port       string
+		want       string
+	}{
+		{"defaults", "", "", ":8080"},
+		{"port", "", "3000", ":3000"},
+		{"listen address wins", "127.0.0.1:9000", "3000", "127.0.0.1:9000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LISTEN_ADDR", tt.listenAddr)
+			t.Setenv("PORT", tt.port)
+			if got := listenAddr(); got != tt.want {
+				t.Errorf("listenAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenAddrBinds(t *testing.T) {
+	t.Setenv("LISTEN_ADDR", "127.0.0.1:0")
+	t.Setenv("PORT", "3000")
+
+	listener, err := net.Listen("tcp", listenAddr())
+	if err != nil {
+		t.Fatalf("listening on %s: %v", listenAddr(), err)
+	}
+	defer listener.Close()
+	if addr := listener.Addr().(*net.TCPAddr); !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) || addr.Port == 3000 {
+		t.Errorf("bound %s, want loopback on an ephemeral port", addr)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "time"
+
+// Crowd levels, from emptiest to busiest
+const (
+	CROWD_LOW    = "low"
+	CROWD_MEDIUM = "medium"
+	CROWD_HIGH   = "high"
+)
+
+// Popularity is rated 1-10. Spots registered without one are assumed to be
+// middling.
+const (
+	MAX_SPOT_POPULARITY     = 10
+	DEFAULT_SPOT_POPULARITY = 5
+)
+
+// estimateCrowd guesses how busy a spot is at t, given in the spot's local
+// time. Popularity is scaled by how busy that part of the day usually is:
+// mornings draw the most surfers, weekends more than weekdays, and nobody is
+// out after dark.
+func estimateCrowd(spotPopularity int, t time.Time) string {
+	if spotPopularity <= 0 {
+		spotPopularity = DEFAULT_SPOT_POPULARITY
+	}
+	weekend := t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+
+	var factor float64
+	switch hour := t.Hour(); {
+	case hour < 5 || hour >= 20:
+		factor = 0
+	case hour < 11:
+		factor = 0.8
+		if weekend {
+			factor = 1.2
+		}
+	case hour < 16:
+		factor = 0.6
+		if weekend {
+			factor = 1.0
+		}
+	default:
+		// After work on weekdays, winding down at weekends
+		factor = 0.8
+	}
+
+	switch busy := float64(spotPopularity) * factor; {
+	case busy < 4:
+		return CROWD_LOW
+	case busy < 7:
+		return CROWD_MEDIUM
+	default:
+		return CROWD_HIGH
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateCrowd(t *testing.T) {
+	// 2024-06-03 was a Monday and 2024-06-08 a Saturday
+	weekday := func(hour int) time.Time { return time.Date(2024, 6, 3, hour, 0, 0, 0, time.UTC) }
+	weekend := func(hour int) time.Time { return time.Date(2024, 6, 8, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		popularity int
+		at         time.Time
+		want       string
+	}{
+		{"popular weekend morning", 9, weekend(8), CROWD_HIGH},
+		{"popular weekday morning", 9, weekday(8), CROWD_HIGH},
+		{"middling weekend morning", 6, weekend(8), CROWD_HIGH},
+		{"middling weekday morning", 6, weekday(8), CROWD_MEDIUM},
+		{"popular weekday midday", 9, weekday(13), CROWD_MEDIUM},
+		{"popular at night", 10, weekday(22), CROWD_LOW},
+		{"popular before dawn", 10, weekend(4), CROWD_LOW},
+		{"unrated weekday morning", 0, weekday(8), CROWD_MEDIUM},
+		{"unrated weekday evening", 0, weekday(17), CROWD_MEDIUM},
+		{"quiet weekend midday", 3, weekend(13), CROWD_LOW},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateCrowd(tt.popularity, tt.at); got != tt.want {
+				t.Errorf("estimateCrowd(%d, %v) = %q, want %q", tt.popularity, tt.at, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+// dashboardTemplate renders every spot's current conditions as a table, with
+// no scripts or external assets so it works anywhere
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Surf Tracker</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.4em 1em; border-bottom: 1px solid #ccc; text-align: left; }
+.error { color: #a00; }
+</style>
+</head>
+<body>
+<h1>Surf Tracker</h1>
+<table>
+<tr><th>Spot</th><th>Waves</th><th>Wind</th><th>Rating</th></tr>
+{{- range . }}
+<tr>
+<td>{{ .Location }}</td>
+{{- if .Error }}
+<td colspan="3" class="error">{{ .Error }}</td>
+{{- else }}
+<td>{{ printf "%.1f" .WaveHeightFt }} ft{{ if .SwellCompass }} {{ .SwellCompass }}{{ end }}</td>
+<td>{{ .WindSpeed }} {{ .WindDirection }}</td>
+<td>{{ .Rating }} ({{ .Score }}/100)</td>
+{{- end }}
+</tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// handleDashboard serves a small HTML page with the current forecast for
+// every known spot, for a quick look in a browser
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	spots := knownSpots.List()
+	spotIDs := make([]string, len(spots))
+	for i, spot := range spots {
+		spotIDs[i] = spot.SpotID
+	}
+	forecasts := getForecasts(r.Context(), spotIDs, false, UNITS_IMPERIAL)
+
+	var page bytes.Buffer
+	if err := dashboardTemplate.Execute(&page, forecasts); err != nil {
+		slog.ErrorContext(r.Context(), "could not render dashboard", "event", "encode_failed", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Failed to render dashboard")
+		return
+	}
+	writeBody(w, r, "text/html; charset=utf-8", page.Bytes())
+}
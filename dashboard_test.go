@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleDashboard(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		want        int
+		wantContain []string
+	}{
+		{"get", http.MethodGet, http.StatusOK, []string{"<td>Malibu, CA</td>", "<td>2.0 ft SSW</td>", `<td colspan="3" class="error">`}},
+		{"wrong method", http.MethodPost, http.StatusMethodNotAllowed, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSpots(t)
+			useProvider(t, heightsProvider(map[string]float64{malibu: 2, huntington: 5}))
+
+			w := httptest.NewRecorder()
+			handleDashboard(w, httptest.NewRequest(tt.method, "/dashboard", nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			page := w.Body.String()
+			for _, want := range tt.wantContain {
+				if !strings.Contains(page, want) {
+					t.Errorf("page is missing %q:\n%s", want, page)
+				}
+			}
+			// Every spot is listed, including those whose fetch failed
+			for _, spot := range defaultSpots {
+				if !strings.Contains(page, ">"+spot.Location+"<") {
+					t.Errorf("page is missing %s", spot.Location)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Default location of the favorites file, see FAVORITES_FILE. Setting
+// FAVORITES_FILE to an empty string keeps favorites in memory only.
+const DEFAULT_FAVORITES_FILE = "favorites.json"
+
+// Longest user name accepted by the favorites endpoints
+const MAX_FAVORITES_USER_LENGTH = 64
+
+// Most spots a single user can save
+const MAX_FAVORITES_PER_USER = 50
+
+// favoritesStore keeps each user's saved spot IDs, written through to a
+// JSON file on every change when a path is configured
+type favoritesStore struct {
+	mu    sync.RWMutex
+	path  string
+	users map[string][]string
+}
+
+// newFavoritesStore loads any favorites previously saved at path. An empty
+// path keeps favorites in memory only.
+func newFavoritesStore(path string) (*favoritesStore, error) {
+	store := &favoritesStore{path: path, users: make(map[string][]string)}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.users); err != nil {
+		return nil, fmt.Errorf("parsing favorites file %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Get returns a user's saved spot IDs
+func (f *favoritesStore) Get(user string) []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return append([]string{}, f.users[user]...)
+}
+
+// Set replaces a user's saved spot IDs and persists the change
+func (f *favoritesStore) Set(user string, spotIDs []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(spotIDs) == 0 {
+		delete(f.users, user)
+	} else {
+		f.users[user] = append([]string{}, spotIDs...)
+	}
+
+	if f.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(f.users)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, data)
+}
+
+// Saved favorites, replaced in main once FAVORITES_FILE is loaded
+var favorites = &favoritesStore{users: make(map[string][]string)}
+
+type favoritesBody struct {
+	User    string   `json:"user,omitempty"`
+	SpotIDs []string `json:"spotIds"`
+}
+
+// favoritesUser returns the user query parameter, writing a 400 if it is
+// missing or too long.
+//
+// The user is a name the client picks, not a verified identity: anyone who
+// knows a name can read or replace that list. Favorites hold nothing more
+// sensitive than spot IDs, so authenticating users is deliberately out of
+// scope here.
+func favoritesUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user := r.URL.Query().Get("user")
+	if user == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_PARAMETER, "Missing user parameter")
+		return "", false
+	}
+	if len(user) > MAX_FAVORITES_USER_LENGTH {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "user parameter is too long")
+		return "", false
+	}
+	return user, true
+}
+
+// handleFavorites returns a user's saved spots on GET and replaces them on
+// PUT with a {"spotIds":[...]} body
+func handleFavorites(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	user, ok := favoritesUser(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSONResponse(w, r, favoritesBody{User: user, SpotIDs: favorites.Get(user)})
+	case http.MethodPut:
+		var body favoritesBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if len(body.SpotIDs) > MAX_FAVORITES_PER_USER {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, fmt.Sprintf("At most %d favorites are allowed", MAX_FAVORITES_PER_USER))
+			return
+		}
+
+		spotIDs := make([]string, 0, len(body.SpotIDs))
+		seen := make(map[string]bool)
+		for _, spotID := range body.SpotIDs {
+			if !validSpotID(spotID) {
+				writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+				return
+			}
+			if _, ok := lookupSpot(r.Context(), spotID); !ok {
+				writeJSONError(w, http.StatusBadRequest, ERR_UNKNOWN_SPOT, "unknown spotId "+spotID)
+				return
+			}
+			if !seen[spotID] {
+				seen[spotID] = true
+				spotIDs = append(spotIDs, spotID)
+			}
+		}
+
+		if err := favorites.Set(user, spotIDs); err != nil {
+			slog.ErrorContext(r.Context(), "could not save favorites", "event", "favorites_save_failed", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Failed to save favorites")
+			return
+		}
+		writeJSONResponse(w, r, favoritesBody{User: user, SpotIDs: spotIDs})
+	}
+}
+
+// handleFavoritesForecast returns forecasts for every spot a user has saved,
+// accepting the same units and bypassCache options as /forecast
+func handleFavoritesForecast(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	user, ok := favoritesUser(w, r)
+	if !ok {
+		return
+	}
+
+	units := r.URL.Query().Get("units")
+	if units == "" {
+		units = UNITS_IMPERIAL
+	}
+	if units != UNITS_IMPERIAL && units != UNITS_METRIC {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid units parameter, expected imperial or metric")
+		return
+	}
+	bypassCache, _ := strconv.ParseBool(r.URL.Query().Get("bypassCache"))
+
+	writeForecastResponse(w, r, getForecasts(r.Context(), favorites.Get(user), bypassCache, units))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useFavorites starts the test with no saved favorites, persisted to path
+// when it is set
+func useFavorites(t *testing.T, path string) {
+	t.Helper()
+	store, err := newFavoritesStore(path)
+	if err != nil {
+		t.Fatalf("newFavoritesStore() error = %v", err)
+	}
+	setForTest(t, &favorites, store)
+}
+
+func TestHandleFavorites(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		body      string
+		want      int
+		wantAllow string
+		wantBody  string
+	}{
+		{"post without user", http.MethodPost, "/favorites", "", http.StatusMethodNotAllowed, "GET, PUT", "Method not allowed"},
+		{"delete with user", http.MethodDelete, "/favorites?user=kai", "", http.StatusMethodNotAllowed, "GET, PUT", "Method not allowed"},
+		{"get without user", http.MethodGet, "/favorites", "", http.StatusBadRequest, "", "Missing user parameter"},
+		{"user too long", http.MethodGet, "/favorites?user=" + strings.Repeat("k", MAX_FAVORITES_USER_LENGTH+1), "", http.StatusBadRequest, "", "too long"},
+		{"get nothing saved", http.MethodGet, "/favorites?user=kai", "", http.StatusOK, "", `"spotIds":[]`},
+		{"put invalid json", http.MethodPut, "/favorites?user=kai", `{"spotIds":`, http.StatusBadRequest, "", "Invalid JSON body"},
+		{"put unknown spot", http.MethodPut, "/favorites?user=kai", `{"spotIds":["000000000000000000000000"]}`, http.StatusBadRequest, "", "unknown spotId"},
+		{"put invalid spot", http.MethodPut, "/favorites?user=kai", `{"spotIds":["nope"]}`, http.StatusBadRequest, "", "invalid spotId format"},
+		{"put deduplicates", http.MethodPut, "/favorites?user=kai", `{"spotIds":["5842041f4e65fad6a7708814","5842041f4e65fad6a7708814"]}`, http.StatusOK, "", `"spotIds":["5842041f4e65fad6a7708814"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFavorites(t, "")
+
+			w := httptest.NewRecorder()
+			handleFavorites(w, httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+// Saved favorites are returned on GET and survive reloading the file
+func TestFavoritesSaveAndRetrieve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.json")
+	useFavorites(t, path)
+
+	w := httptest.NewRecorder()
+	body := `{"spotIds":["` + malibu + `","` + tamarindo + `"]}`
+	handleFavorites(w, httptest.NewRequest(http.MethodPut, "/favorites?user=kai", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	handleFavorites(w, httptest.NewRequest(http.MethodGet, "/favorites?user=kai", nil))
+	var got favoritesBody
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	if got.User != "kai" || strings.Join(got.SpotIDs, ",") != malibu+","+tamarindo {
+		t.Errorf("GET = %+v", got)
+	}
+
+	reloaded, err := newFavoritesStore(path)
+	if err != nil {
+		t.Fatalf("reloading favorites: %v", err)
+	}
+	if ids := reloaded.Get("kai"); strings.Join(ids, ",") != malibu+","+tamarindo {
+		t.Errorf("reloaded favorites = %v", ids)
+	}
+	if ids := reloaded.Get("other"); len(ids) != 0 {
+		t.Errorf("another user has favorites %v", ids)
+	}
+}
+
+func TestNewFavoritesStoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newFavoritesStore(filepath.Join(dir, "missing.json")); err != nil {
+		t.Errorf("missing file: error = %v, want an empty store", err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFavoritesStore(corrupt); err == nil {
+		t.Error("corrupt file: error = nil, want a parse error")
+	}
+}
+
+func TestHandleFavoritesForecast(t *testing.T) {
+	useCache(t)
+	useFavorites(t, "")
+	if err := favorites.Set("kai", []string{malibu, tamarindo}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		want    int
+		wantIDs []string
+	}{
+		{"saved spots", "/favorites/forecast?user=kai", http.StatusOK, []string{malibu, tamarindo}},
+		{"metric", "/favorites/forecast?user=kai&units=metric", http.StatusOK, []string{malibu, tamarindo}},
+		{"nothing saved", "/favorites/forecast?user=nobody", http.StatusOK, []string{}},
+		{"missing user", "/favorites/forecast", http.StatusBadRequest, nil},
+		{"invalid units", "/favorites/forecast?user=kai&units=kelvin", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleFavoritesForecast(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var responses []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if len(responses) != len(tt.wantIDs) {
+				t.Fatalf("got %d forecasts, want %d", len(responses), len(tt.wantIDs))
+			}
+			for i, resp := range responses {
+				if resp.SpotID != tt.wantIDs[i] || resp.Error != "" {
+					t.Errorf("forecast %d = %s (error %q), want %s", i, resp.SpotID, resp.Error, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
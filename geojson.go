@@ -0,0 +1,48 @@
+package main
+
+import "net/http"
+
+// GeoJSON (RFC 7946) types for the subset the spot map needs
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   GeoJSONPoint      `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+// GeoJSONPoint holds a position as [lon, lat], the order GeoJSON requires
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// spotFeatures returns the known spots as a FeatureCollection of points
+func spotFeatures() GeoJSONFeatureCollection {
+	spots := knownSpots.List()
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]GeoJSONFeature, 0, len(spots))}
+	for _, spot := range spots {
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: GeoJSONPoint{Type: "Point", Coordinates: [2]float64{spot.Lon, spot.Lat}},
+			Properties: map[string]string{
+				"spotId":   spot.SpotID,
+				"location": spot.Location,
+			},
+		})
+	}
+	return collection
+}
+
+// handleSpotsGeoJSON serves the spot locations for map clients, with the
+// properties named in the JSON_FIELD_STYLE
+func handleSpotsGeoJSON(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSONAs(w, r, http.StatusOK, "application/geo+json", spotFeatures())
+}
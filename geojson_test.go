@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleSpotsGeoJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		want   int
+	}{
+		{"get", http.MethodGet, http.StatusOK},
+		{"wrong method", http.MethodPost, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSpots(t)
+
+			w := httptest.NewRecorder()
+			handleSpotsGeoJSON(w, httptest.NewRequest(tt.method, "/spots.geojson", nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.method != http.MethodGet {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/geo+json" {
+				t.Errorf("Content-Type = %q, want application/geo+json", got)
+			}
+			var collection GeoJSONFeatureCollection
+			if err := json.Unmarshal(w.Body.Bytes(), &collection); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if collection.Type != "FeatureCollection" || len(collection.Features) != len(defaultSpots) {
+				t.Fatalf("got a %s of %d features, want %d", collection.Type, len(collection.Features), len(defaultSpots))
+			}
+		})
+	}
+}
+
+func TestSpotFeatures(t *testing.T) {
+	useSpots(t)
+	for _, feature := range spotFeatures().Features {
+		spot, ok := knownSpots.Get(feature.Properties["spotId"])
+		if !ok {
+			t.Errorf("feature for unknown spot %q", feature.Properties["spotId"])
+			continue
+		}
+		// GeoJSON puts longitude first
+		if feature.Geometry.Coordinates != [2]float64{spot.Lon, spot.Lat} {
+			t.Errorf("%s coordinates = %v, want [%v %v]", spot.SpotID, feature.Geometry.Coordinates, spot.Lon, spot.Lat)
+		}
+		if feature.Type != "Feature" || feature.Geometry.Type != "Point" || feature.Properties["location"] != spot.Location {
+			t.Errorf("%s feature = %+v", spot.SpotID, feature)
+		}
+	}
+}
@@ -1,8 +1,19 @@
 module surftracker
 
-go 1.20
+go 1.21
 
 require (
-    github.com/mhelmetag/surflinef v0.0.0-20220103050940-e5cb7098e4da
-    github.com/rs/cors v1.10.1
+	github.com/prometheus/client_golang v1.19.1
+	github.com/rs/cors v1.10.1
+	golang.org/x/sync v0.7.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 )
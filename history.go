@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Default number of forecasts kept per spot, see HISTORY_SIZE
+const DEFAULT_HISTORY_SIZE = 48
+
+// historyStore keeps the most recent fetched forecasts for each spot in a
+// fixed-size ring buffer
+type historyStore struct {
+	mu    sync.Mutex
+	size  int
+	rings map[string]*historyRing
+}
+
+type historyRing struct {
+	entries []ForecastResponse
+	next    int // index the next entry is written to
+	full    bool
+}
+
+func newHistoryStore(size int) *historyStore {
+	if size <= 0 {
+		size = DEFAULT_HISTORY_SIZE
+	}
+	return &historyStore{size: size, rings: make(map[string]*historyRing)}
+}
+
+// Add records a forecast for a spot, overwriting the oldest once full
+func (h *historyStore) Add(spotID string, resp ForecastResponse) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, ok := h.rings[spotID]
+	if !ok {
+		ring = &historyRing{entries: make([]ForecastResponse, h.size)}
+		h.rings[spotID] = ring
+	}
+	ring.entries[ring.next] = resp
+	ring.next = (ring.next + 1) % h.size
+	if ring.next == 0 {
+		ring.full = true
+	}
+}
+
+// Get returns a spot's recorded forecasts, oldest first
+func (h *historyStore) Get(spotID string) []ForecastResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, ok := h.rings[spotID]
+	if !ok {
+		return []ForecastResponse{}
+	}
+	if !ring.full {
+		return append([]ForecastResponse{}, ring.entries[:ring.next]...)
+	}
+	history := make([]ForecastResponse, 0, h.size)
+	history = append(history, ring.entries[ring.next:]...)
+	return append(history, ring.entries[:ring.next]...)
+}
+
+// Delete forgets a spot's history
+func (h *historyStore) Delete(spotID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.rings, spotID)
+}
+
+// Wave height trends
+const (
+	TREND_BUILDING = "building"
+	TREND_HOLDING  = "holding"
+	TREND_DROPPING = "dropping"
+)
+
+// Number of recent forecasts averaged when computing a trend
+const TREND_WINDOW = 3
+
+// Default change in feet that counts as building or dropping, see
+// TREND_THRESHOLD_FT
+const DEFAULT_TREND_THRESHOLD_FT = 0.5
+
+// computeTrend compares the current wave height with the average of recent
+// ones. A change smaller than trendThresholdFt, or no history at all, is
+// holding.
+func computeTrend(current float64, recent []float64) string {
+	if len(recent) == 0 {
+		return TREND_HOLDING
+	}
+	var sum float64
+	for _, height := range recent {
+		sum += height
+	}
+	change := current - sum/float64(len(recent))
+
+	switch {
+	case change >= trendThresholdFt:
+		return TREND_BUILDING
+	case change <= -trendThresholdFt:
+		return TREND_DROPPING
+	default:
+		return TREND_HOLDING
+	}
+}
+
+// recentWaveHeights returns the wave heights of the last n forecasts in
+// history, skipping those without swell data
+func recentWaveHeights(history []ForecastResponse, n int) []float64 {
+	var heights []float64
+	for i := len(history) - 1; i >= 0 && len(heights) < n; i-- {
+		if history[i].WaveHeightFt > 0 {
+			heights = append(heights, history[i].WaveHeightFt)
+		}
+	}
+	return heights
+}
+
+// Recent forecasts per spot, replaced in main once HISTORY_SIZE is known
+var forecastHistory = newHistoryStore(DEFAULT_HISTORY_SIZE)
+
+// handleHistory returns the recorded forecasts for a spot, oldest first
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	spotID := r.URL.Query().Get("spotId")
+	if spotID == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing spotId parameter")
+		return
+	}
+	if !validSpotID(spotID) {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+		return
+	}
+	if _, ok := lookupSpot(r.Context(), spotID); !ok {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spotId")
+		return
+	}
+
+	writeJSONResponse(w, r, forecastHistory.Get(spotID))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHistoryStoreRing(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		added []float64
+		want  []float64
+	}{
+		{"empty", 3, nil, []float64{}},
+		{"partly filled", 3, []float64{1, 2}, []float64{1, 2}},
+		{"exactly full", 3, []float64{1, 2, 3}, []float64{1, 2, 3}},
+		{"wrapped", 3, []float64{1, 2, 3, 4, 5}, []float64{3, 4, 5}},
+		{"default size", 0, []float64{1}, []float64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newHistoryStore(tt.size)
+			for _, height := range tt.added {
+				store.Add(malibu, ForecastResponse{WaveHeightFt: height})
+			}
+			got := store.Get(malibu)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(got), len(tt.want))
+			}
+			for i, resp := range got {
+				if resp.WaveHeightFt != tt.want[i] {
+					t.Errorf("entry %d = %v, want %v", i, resp.WaveHeightFt, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryStoreDelete(t *testing.T) {
+	store := newHistoryStore(3)
+	store.Add(malibu, ForecastResponse{WaveHeightFt: 1})
+	store.Add(huntington, ForecastResponse{WaveHeightFt: 2})
+	store.Delete(malibu)
+
+	if got := store.Get(malibu); len(got) != 0 {
+		t.Errorf("deleted spot has %d entries", len(got))
+	}
+	if got := store.Get(huntington); len(got) != 1 {
+		t.Errorf("other spot has %d entries, want 1", len(got))
+	}
+}
+
+// Each fetch is recorded, so the oldest drop out once the buffer is full
+func TestHistoryRecordsFetches(t *testing.T) {
+	provider := newFakeProvider()
+	timestamp := int64(1700000000)
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		timestamp += 3600
+		resp := fakeForecast(spotID, 3)
+		resp.Timestamp = timestamp
+		return resp, nil
+	}
+	useProvider(t, provider)
+	setForTest(t, &forecastHistory, newHistoryStore(3))
+
+	for i := 0; i < 5; i++ {
+		if _, err := getForecast(context.Background(), malibu, true); err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handleHistory(w, httptest.NewRequest(http.MethodGet, "/forecast/history?spotId="+malibu, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var history []ForecastResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	want := []int64{1700010800, 1700014400, 1700018000}
+	if len(history) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(history), len(want))
+	}
+	for i, resp := range history {
+		if resp.Timestamp != want[i] {
+			t.Errorf("entry %d timestamp = %d, want %d", i, resp.Timestamp, want[i])
+		}
+	}
+}
+
+func TestComputeTrend(t *testing.T) {
+	tests := []struct {
+		name    string
+		current float64
+		recent  []float64
+		want    string
+	}{
+		{"no history", 4, nil, TREND_HOLDING},
+		{"building", 4, []float64{3, 3.5, 3.5}, TREND_BUILDING},
+		{"dropping", 2, []float64{3, 2.5, 2.5}, TREND_DROPPING},
+		{"small change", 3.2, []float64{3, 3, 3}, TREND_HOLDING},
+		{"exactly the threshold", 3.5, []float64{3}, TREND_BUILDING},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &trendThresholdFt, DEFAULT_TREND_THRESHOLD_FT)
+			if got := computeTrend(tt.current, tt.recent); got != tt.want {
+				t.Errorf("computeTrend(%v, %v) = %q, want %q", tt.current, tt.recent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecentWaveHeights(t *testing.T) {
+	history := []ForecastResponse{{WaveHeightFt: 1}, {WaveHeightFt: 2}, {}, {WaveHeightFt: 3}}
+	tests := []struct {
+		n    int
+		want []float64
+	}{
+		{0, nil},
+		{2, []float64{3, 2}},
+		{10, []float64{3, 2, 1}},
+	}
+	for _, tt := range tests {
+		got := recentWaveHeights(history, tt.n)
+		if len(got) != len(tt.want) {
+			t.Errorf("recentWaveHeights(%d) = %v, want %v", tt.n, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("recentWaveHeights(%d) = %v, want %v", tt.n, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// Each fetch is compared against the forecasts recorded before it
+func TestForecastTrend(t *testing.T) {
+	heights := []float64{3, 3, 3, 4, 2.5}
+	want := []string{TREND_HOLDING, TREND_HOLDING, TREND_HOLDING, TREND_BUILDING, TREND_DROPPING}
+	provider := newFakeProvider()
+	fetches := 0
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		resp := fakeForecast(spotID, heights[fetches])
+		fetches++
+		return resp, nil
+	}
+	useProvider(t, provider)
+	setForTest(t, &forecastHistory, newHistoryStore(DEFAULT_HISTORY_SIZE))
+
+	for i := range heights {
+		resp, err := getForecast(context.Background(), malibu, true)
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if resp.Trend != want[i] {
+			t.Errorf("fetch %d of %v ft: Trend = %q, want %q", i, heights[i], resp.Trend, want[i])
+		}
+	}
+}
+
+func TestHandleHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantErr string
+		wantLen int
+	}{
+		{"recorded spot", "?spotId=" + malibu, http.StatusOK, "", 2},
+		{"nothing recorded", "?spotId=" + huntington, http.StatusOK, "", 0},
+		{"missing spot", "", http.StatusBadRequest, "Missing spotId parameter", 0},
+		{"invalid spot", "?spotId=nope", http.StatusBadRequest, "invalid spotId format", 0},
+		{"unknown spot", "?spotId=" + unknownSpotID, http.StatusNotFound, "unknown spotId", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNegativeCache(t)
+			setForTest(t, &forecastHistory, newHistoryStore(3))
+			forecastHistory.Add(malibu, fakeForecast(malibu, 3))
+			forecastHistory.Add(malibu, fakeForecast(malibu, 4))
+
+			w := httptest.NewRecorder()
+			handleHistory(w, httptest.NewRequest(http.MethodGet, "/forecast/history"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantErr != "" {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+				if body["error"] != tt.wantErr {
+					t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+				}
+				return
+			}
+			var history []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if len(history) != tt.wantLen {
+				t.Errorf("got %d entries, want %d", len(history), tt.wantLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logLevel is the minimum level logged, set from LOG_LEVEL by loadConfig
+var logLevel slog.LevelVar
+
+// parseLogLevel maps a LOG_LEVEL value (debug, info, warn or error) to a
+// slog level. An empty value selects info.
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, err
+	}
+	return level, nil
+}
+
+// contextHandler adds request-scoped attributes, such as the request ID, to
+// records logged with a request's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID, ok := requestIDFromContext(ctx); ok {
+		record.AddAttrs(slog.String("requestId", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
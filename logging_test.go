@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingHandler keeps every record logged through it, so tests can assert
+// on attributes rather than on formatted output
+type recordingHandler struct {
+	mu      *sync.Mutex
+	records *[]slog.Record
+	level   slog.Leveler
+	attrs   []slog.Attr
+}
+
+func (h recordingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.level == nil || level >= h.level.Level()
+}
+
+func (h recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	r = r.Clone()
+	r.AddAttrs(h.attrs...)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.records = append(*h.records, r)
+	return nil
+}
+
+func (h recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return h
+}
+
+func (h recordingHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// find returns the attributes of the first record whose event is event
+func (h recordingHandler) find(event string) (map[string]string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range *h.records {
+		attrs := make(map[string]string)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value.String()
+			return true
+		})
+		if attrs["event"] == event {
+			return attrs, true
+		}
+	}
+	return nil, false
+}
+
+// recordLogs sends the default logger to a recordingHandler for the test
+func recordLogs(t *testing.T) recordingHandler {
+	t.Helper()
+	h := recordingHandler{mu: &sync.Mutex{}, records: &[]slog.Record{}}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(h))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return h
+}
+
+func TestForecastLogAttributes(t *testing.T) {
+	tests := []struct {
+		name        string
+		bypassCache bool
+		event       string
+		wantCache   string
+	}{
+		{"cache hit", false, "cache_hit", "hit"},
+		{"bypass", true, "fetch", "bypass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCache(t)
+			if _, err := getForecast(context.Background(), malibu, false); err != nil {
+				t.Fatalf("getForecast() error = %v", err)
+			}
+			logs := recordLogs(t)
+			if _, err := getForecast(context.Background(), malibu, tt.bypassCache); err != nil {
+				t.Fatalf("getForecast() error = %v", err)
+			}
+
+			attrs, ok := logs.find(tt.event)
+			if !ok {
+				t.Fatalf("no %s record was logged", tt.event)
+			}
+			if attrs["spotId"] != malibu || attrs["cache"] != tt.wantCache {
+				t.Errorf("%s attributes = %v, want spotId %s and cache %s", tt.event, attrs, malibu, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"loud", slog.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseLogLevel(tt.s)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("parseLogLevel(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// With LOG_LEVEL=warn the per-request cache logs and other info records are
+// dropped while warnings still get through
+func TestLogLevelWarn(t *testing.T) {
+	previous := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(previous) })
+	t.Setenv("LOG_LEVEL", "warn")
+	setForTest(t, &cacheDuration, cacheDuration)
+	loadConfig()
+
+	logs := recordLogs(t)
+	logs.level = &logLevel
+	slog.SetDefault(slog.New(logs))
+	useCache(t)
+	for i := 0; i < 2; i++ {
+		if _, err := getForecast(context.Background(), malibu, false); err != nil {
+			t.Fatalf("getForecast() error = %v", err)
+		}
+	}
+	slog.Info("info record", "event", "info_record")
+	slog.Warn("warn record", "event", "warn_record")
+
+	for _, event := range []string{"fetch", "cache_hit", "info_record"} {
+		if _, ok := logs.find(event); ok {
+			t.Errorf("%s was logged at LOG_LEVEL=warn", event)
+		}
+	}
+	if _, ok := logs.find("warn_record"); !ok {
+		t.Error("warn record was suppressed")
+	}
+}
+
+func TestContextHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"with request ID", context.WithValue(context.Background(), requestIDKey{}, "req-123"), "requestId=req-123"},
+		{"without request ID", context.Background(), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := slog.New(contextHandler{slog.NewTextHandler(&out, nil)}).With("component", "test").WithGroup("g")
+			logger.InfoContext(tt.ctx, "hello", "k", "v")
+
+			line := out.String()
+			if !strings.Contains(line, "component=test") || !strings.Contains(line, "g.k=v") {
+				t.Errorf("log line %q lost its attributes or group", line)
+			}
+			if tt.want != "" && !strings.Contains(line, tt.want) {
+				t.Errorf("log line %q is missing %q", line, tt.want)
+			}
+			if tt.want == "" && strings.Contains(line, "requestId") {
+				t.Errorf("log line %q has a request ID", line)
+			}
+		})
+	}
+}
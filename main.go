@@ -1,15 +1,32 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/singleflight"
 )
 
+type Swell struct {
+	HeightFt     float64 `json:"heightFt"`
+	PeriodSec    int     `json:"periodSec"`
+	DirectionDeg int     `json:"directionDeg"`
+}
+
 type ForecastResponse struct {
 	SpotID         string `json:"spotId"`
 	Location       string `json:"location"`
@@ -18,54 +35,365 @@ type ForecastResponse struct {
 	WindDirection  string `json:"windDirection"`
 	Tide           string `json:"tide"`
 	Timestamp      int64  `json:"timestamp"`
-}
 
-// Map of Surfline spot IDs to location names
-var spotLocations = map[string]string{
-	"5842041f4e65fad6a7708814": "Malibu, CA",
-	"5842041f4e65fad6a770883d": "Huntington Beach, CA",
-	"5842041f4e65fad6a7709115": "Tamarindo, CR",
-	"5842041f4e65fad6a7709117": "Jaco, CR",
-	"5842041f4e65fad6a7709116": "Dominical, CR",
-}
+	// Numeric values parsed from WaveHeight. WaveHeightFt is the middle of
+	// the reported range, or the height itself when there is no range.
+	WaveHeightFt      float64 `json:"waveHeightFt"`
+	WaveHeightMinFt   float64 `json:"waveHeightMinFt"`
+	WaveHeightMaxFt   float64 `json:"waveHeightMaxFt"`
+	SwellPeriodSec    int     `json:"swellPeriodSec"`
+	SwellDirectionDeg int     `json:"swellDirectionDeg"`
+	SwellCompass      string  `json:"swellCompass"`
+
+	// Individual swell trains, largest (primary) first
+	Swells []Swell `json:"swells"`
+
+	// Power of the primary swell, see computeEnergy
+	EnergyKJ float64 `json:"energyKJ"`
+
+	// Upcoming tide turns in chronological order
+	TideEvents []TideEvent `json:"tideEvents"`
+
+	// Bearing the wind blows from and how it meets the beach
+	WindDegrees  int    `json:"windDegrees"`
+	WindRelative string `json:"windRelative"`
+
+	// Sustained and peak gust speeds parsed from WindSpeed, in km/h when
+	// Units is metric
+	WindSpeedMph float64 `json:"windSpeedMph"`
+	WindGustMph  float64 `json:"windGustMph"`
+
+	// First and last light at the spot on the forecast's day, as unix
+	// timestamps. Zero when the sun doesn't rise or set.
+	Sunrise int64 `json:"sunrise"`
+	Sunset  int64 `json:"sunset"`
+
+	// Clear-sky UV index and its category, see uvCategory
+	UVIndex    float64 `json:"uvIndex"`
+	UVCategory string  `json:"uvCategory"`
+
+	// Rough guess at how busy the spot is now: low, medium or high
+	CrowdLevel string `json:"crowdLevel"`
+
+	// Temperatures in Fahrenheit, or Celsius when Units is metric. Zero when
+	// the source has no temperature data.
+	WaterTempF float64 `json:"waterTempF"`
+	AirTempF   float64 `json:"airTempF"`
+
+	// Overall surf quality, see rateConditions
+	Score  int    `json:"score"`
+	Rating string `json:"rating"`
+
+	// Whether the surf is building, holding or dropping, see computeTrend
+	Trend string `json:"trend"`
 
-// Simple in-memory cache
-var forecastCache = make(map[string]CacheItem)
+	// Unit system of the measurements, "imperial" or "metric"
+	Units string `json:"units"`
 
-type CacheItem struct {
-	Response  ForecastResponse
-	ExpiresAt int64
+	// Set when an expired cache entry is served while it is refreshed
+	Stale bool `json:"stale"`
+
+	// When the cached copy of this forecast expires, in unix seconds;
+	// there's no point asking again before then
+	FreshUntil int64 `json:"freshUntil"`
+
+	// Where the data came from, SOURCE_LIVE or SOURCE_MOCK. A cached copy
+	// keeps the origin it was fetched from.
+	Source string `json:"source"`
+
+	// How getForecast served this forecast: "hit", "stale", "miss" or
+	// "bypass". Sent as the X-Cache header rather than in the body, so a
+	// cache hit carries the same ETag as the fetch that filled it.
+	cacheStatus string
+
+	// Credit the data provider requires on live data, see ATTRIBUTION_TEXT.
+	// It stays with the forecast when it is served from cache.
+	Attribution string `json:"attribution,omitempty"`
+
+	// Set on batch entries that could not be served
+	Error string `json:"error,omitempty"`
+
+	// Response schema version, see API_VERSION
+	ApiVersion string `json:"apiVersion"`
 }
 
-const CACHE_DURATION = 30 * 60 // 30 minutes in seconds
+// In-memory cache, replaced in main once CACHE_MAX_ENTRIES is known
+var forecastCache = newForecastCacheStore(DEFAULT_CACHE_MAX_ENTRIES, 0)
+
+const CACHE_DURATION = 30 * 60 // default of 30 minutes in seconds, see CACHE_DURATION_SECONDS
+
+const SHUTDOWN_TIMEOUT = 10 * time.Second
+
+// Time allowed for the provider call made by /health?deep=true
+const HEALTH_CHECK_TIMEOUT = 2 * time.Second
+
+// Default server timeouts in seconds, see READ_HEADER_TIMEOUT_SECONDS,
+// READ_TIMEOUT_SECONDS, WRITE_TIMEOUT_SECONDS and IDLE_TIMEOUT_SECONDS. The
+// write timeout leaves room for a full /forecast/watch long poll.
+const (
+	DEFAULT_READ_HEADER_TIMEOUT_SECONDS = 5
+	DEFAULT_READ_TIMEOUT_SECONDS        = 10
+	DEFAULT_WRITE_TIMEOUT_SECONDS       = 90
+	DEFAULT_IDLE_TIMEOUT_SECONDS        = 120
+)
+
+// Longest window a single range request may cover
+const MAX_FORECAST_RANGE = 7 * 24 * time.Hour
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &logLevel})}))
+
+	loadConfig()
+
+	provider, err := newForecastProvider(os.Getenv("FORECAST_SOURCE"))
+	if err != nil {
+		slog.Error("invalid forecast source", "event", "startup_failed", "error", err)
+		os.Exit(1)
 	}
+	forecastProvider = provider
+	if err := validateMockData(); err != nil {
+		slog.Error("mock data does not parse", "event", "startup_failed", "error", err)
+		os.Exit(1)
+	}
+	forecastCache = newForecastCacheStore(cacheMaxEntries, staleGrace)
+	forecastHistory = newHistoryStore(historySize)
+	providerBreaker = newCircuitBreaker(breakerThreshold, breakerCooldown)
+
+	favorites, err = newFavoritesStore(favoritesFile)
+	if err != nil {
+		slog.Error("could not load favorites", "event", "startup_failed", "path", favoritesFile, "error", err)
+		os.Exit(1)
+	}
+	alerts, err = newAlertStore(alertsPath(cacheFile), logNotifier{})
+	if err != nil {
+		slog.Error("could not load alerts", "event", "startup_failed", "path", alertsPath(cacheFile), "error", err)
+		os.Exit(1)
+	}
+
+	if cacheFile != "" {
+		loaded, err := forecastCache.Load(cacheFile)
+		if err != nil {
+			slog.Warn("could not load cache file", "event", "cache_load_failed", "path", cacheFile, "error", err)
+		} else {
+			slog.Info("loaded cache file", "event", "cache_loaded", "path", cacheFile, "entries", loaded)
+		}
+	}
+
+	server := newServer(listenAddr(), newHandler())
+
+	// Background work runs until the server has shut down
+	background, stopBackground := context.WithCancel(context.Background())
+	var backgroundDone sync.WaitGroup
+
+	// The refresher also checks alerts, so it always runs: every
+	// REFRESH_INTERVAL_SECONDS when prefetching, otherwise every
+	// ALERT_CHECK_INTERVAL_SECONDS for the alerted spots alone
+	interval, prefetch := alertCheckInterval, []string(nil)
+	if refreshInterval > 0 {
+		if refreshInterval >= time.Duration(cacheDuration)*time.Second {
+			slog.Warn("refresh interval is not shorter than the cache duration, entries will expire between refreshes", "event", "config_invalid")
+		}
+		interval, prefetch = refreshInterval, prefetchSpots
+	}
+	if interval > 0 {
+		backgroundDone.Add(1)
+		go func() {
+			defer backgroundDone.Done()
+			runRefresher(background, interval, prefetch)
+		}()
+	} else {
+		slog.Warn("alert checks are disabled, alerts will never fire", "event", "config_invalid", "name", "ALERT_CHECK_INTERVAL_SECONDS")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	// Config, provider and cache are all in place, so traffic can be routed here
+	ready.Store(true)
+
+	slog.Info("starting server", "event", "startup", "addr", server.Addr)
+	if err := serve(server, signals); err != nil {
+		slog.Error("server failed", "event", "server_failed", "error", err)
+		os.Exit(1)
+	}
+
+	stopBackground()
+	backgroundDone.Wait()
+
+	if cacheFile != "" {
+		if err := forecastCache.Save(cacheFile); err != nil {
+			slog.Error("could not save cache file", "event", "cache_save_failed", "path", cacheFile, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("saved cache file", "event", "cache_saved", "path", cacheFile)
+	}
+}
+
+// newHandler routes every endpoint and wraps them in the middleware chain
+func newHandler() http.Handler {
+	// API routes are served both unprefixed and under /v1/ so clients can pin
+	// a version
+	api := http.NewServeMux()
+	limiter := newRateLimiter(rateLimitPerMin)
+	api.Handle("/forecast", limiter.middleware(http.HandlerFunc(handleForecast)))
+	api.Handle("/forecast/nearest", limiter.middleware(http.HandlerFunc(handleNearest)))
+	api.Handle("/forecast/byurl", limiter.middleware(http.HandlerFunc(handleByURL)))
+	api.Handle("/forecast/best", limiter.middleware(http.HandlerFunc(handleBest)))
+	api.Handle("/forecast/batch", limiter.middleware(http.HandlerFunc(handleBatch)))
+	api.Handle("/forecast/compare", limiter.middleware(http.HandlerFunc(handleCompare)))
+	api.Handle("/forecast/summary", limiter.middleware(http.HandlerFunc(handleSummary)))
+	api.Handle("/forecast/digest", limiter.middleware(http.HandlerFunc(handleDigest)))
+	api.Handle("/forecast/watch", limiter.middleware(http.HandlerFunc(handleWatch)))
+	api.Handle("/forecast/history", limiter.middleware(http.HandlerFunc(handleHistory)))
+	api.HandleFunc("/favorites", handleFavorites)
+	api.Handle("/favorites/forecast", limiter.middleware(http.HandlerFunc(handleFavoritesForecast)))
+	api.HandleFunc("/alerts", handleAlerts)
+	api.Handle("/dashboard", limiter.middleware(http.HandlerFunc(handleDashboard)))
+	api.HandleFunc("/spots", handleSpots)
+	api.HandleFunc("/spots.geojson", handleSpotsGeoJSON)
+	api.HandleFunc("/spots/search", handleSpotSearch)
+	api.HandleFunc("/spots/", handleSpotDetails)
+	api.HandleFunc("/regions", handleRegions)
+	api.Handle("/cache", requireAdmin(http.HandlerFunc(handleCache)))
+	api.Handle("/cache/refresh", requireAdmin(http.HandlerFunc(handleCacheRefresh)))
+	api.HandleFunc("/cache/stats", handleCacheStats)
+	api.Handle("/cache/stats/reset", requireAdmin(http.HandlerFunc(handleCacheStatsReset)))
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/forecast", handleForecast)
+	mux.Handle("/", requireAPIKey(api))
+	mux.Handle("/v"+API_VERSION+"/", http.StripPrefix("/v"+API_VERSION, requireAPIKey(api)))
 	mux.HandleFunc("/health", handleHealth)
-	
-	log.Printf("Starting server on port %s", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		log.Fatal(err)
+	mux.HandleFunc("/ready", handleReady)
+	mux.HandleFunc("/version", handleVersion)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return requestIDMiddleware(timingMiddleware(corsMiddleware(limitMiddleware(gzipMiddleware(recoverMiddleware(mux))))))
+}
+
+// newServer returns a server for handler with the configured timeouts, so
+// slow or idle clients can't hold connections open indefinitely
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
 	}
 }
 
+// serve runs the server until it fails or a signal arrives, then shuts it
+// down, giving in-flight requests up to SHUTDOWN_TIMEOUT (10s) to complete.
+func serve(server *http.Server, signals <-chan os.Signal) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case sig := <-signals:
+		slog.Info("shutting down", "event", "shutdown", "signal", sig.String())
+	}
+
+	// Stop advertising readiness so load balancers drain us while in-flight
+	// requests finish
+	ready.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	slog.Info("server stopped", "event", "stopped")
+	return nil
+}
+
 func handleHealth(w http.ResponseWriter, r *http.Request) {
+	// Monitors often probe with HEAD to skip the body
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	// The shallow check only proves the process is serving requests
+	deep, _ := strconv.ParseBool(r.URL.Query().Get("deep"))
+	if !deep {
+		writeBody(w, r, "application/json", []byte(`{"status":"ok"}`))
+		return
+	}
+
+	if err := checkProvider(r.Context()); err != nil {
+		slog.WarnContext(r.Context(), "deep health check failed", "event", "health_degraded", "error", err)
+		writeBodyStatus(w, r, http.StatusServiceUnavailable, "application/json", []byte(`{"status":"degraded"}`))
+		return
+	}
+	writeBody(w, r, "application/json", []byte(`{"status":"ok"}`))
+}
+
+// ready is set once startup initialization has finished, and cleared again
+// when shutdown begins
+var ready atomic.Bool
+
+// handleReady reports whether the server should receive traffic. Unlike
+// /health it fails until startup has finished.
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"status":"ok"}`))
+	if !ready.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"not ready"}`))
+		return
+	}
+	w.Write([]byte(`{"status":"ready"}`))
+}
+
+// checkProvider fetches one known spot straight from the provider, bypassing
+// the cache, to confirm the upstream is reachable
+func checkProvider(ctx context.Context) error {
+	spots := listSpots()
+	if len(spots) == 0 {
+		return errors.New("no spots to check")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+	_, err := forecastProvider.Fetch(ctx, spots[0].SpotID)
+	return err
 }
 
 func handleForecast(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+
+	start := appClock.Now()
+	defer func() {
+		forecastRequestDuration.Observe(appClock.Now().Sub(start).Seconds())
+	}()
+
 	w.Header().Set("Content-Type", "application/json")
 	
-	spotID := r.URL.Query().Get("spotId")
-	if spotID == "" {
-		http.Error(w, "Missing spotId parameter", http.StatusBadRequest)
+	spotIDParam := r.URL.Query().Get("spotId")
+	// Spots can also be named by slug, e.g. ?spot=malibu
+	if slug := r.URL.Query().Get("spot"); spotIDParam == "" && slug != "" {
+		spot, ok := spotBySlug(slug)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spot")
+			return
+		}
+		spotIDParam = spot.SpotID
+	}
+	// Single-break deployments can serve their home spot by default
+	if spotIDParam == "" {
+		spotIDParam = defaultSpotID
+	}
+	if spotIDParam == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing spotId parameter")
 		return
 	}
 
@@ -79,69 +407,511 @@ func handleForecast(w http.ResponseWriter, r *http.Request) {
 			bypassCache = false
 		}
 	}
-	
+
+	units := r.URL.Query().Get("units")
+	if units == "" {
+		units = UNITS_IMPERIAL
+	}
+	if units != UNITS_IMPERIAL && units != UNITS_METRIC {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid units parameter, expected imperial or metric")
+		return
+	}
+
+	spotIDs := parseSpotIDs(spotIDParam)
+	if len(spotIDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing spotId parameter")
+		return
+	}
+
+	// Reject garbage before it can reach the cache or provider
+	for _, spotID := range spotIDs {
+		if !validSpotID(spotID) {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+			return
+		}
+	}
+
+	// A time window returns hourly entries for a single spot
+	if r.URL.Query().Has("from") || r.URL.Query().Has("to") {
+		if len(spotIDs) != 1 {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Time ranges are only supported for a single spotId")
+			return
+		}
+		handleForecastRange(w, r, spotIDs[0], units)
+		return
+	}
+
+	// A single spot keeps returning a single object
+	if len(spotIDs) == 1 {
+		spotID := spotIDs[0]
+		if _, ok := lookupSpot(r.Context(), spotID); !ok {
+			writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spotId")
+			return
+		}
+
+		response, err := getForecast(r.Context(), spotID, bypassCache)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error fetching forecast", "event", "fetch_failed", "spotId", spotID, "error", err)
+			writeFetchError(w, err)
+			return
+		}
+		if units == UNITS_METRIC {
+			response = toMetric(response)
+		}
+		setCacheControl(w, spotIDs, bypassCache)
+		setCacheStatus(w, response)
+		writeForecastResponse(w, r, response)
+		return
+	}
+
+	var minWaveFt float64
+	if param := r.URL.Query().Get("minWaveFt"); param != "" {
+		var err error
+		minWaveFt, err = strconv.ParseFloat(param, 64)
+		if err != nil || minWaveFt < 0 {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid minWaveFt parameter")
+			return
+		}
+	}
+	var minPeriodSec int
+	if param := r.URL.Query().Get("minPeriodSec"); param != "" {
+		var err error
+		minPeriodSec, err = strconv.Atoi(param)
+		if err != nil || minPeriodSec < 0 {
+			writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid minPeriodSec parameter")
+			return
+		}
+	}
+
+	responses := getForecasts(r.Context(), spotIDs, bypassCache, units)
+	if minWaveFt > 0 {
+		responses = filterMinWaveHeight(responses, minWaveFt)
+	}
+	if minPeriodSec > 0 {
+		responses = filterMinSwellPeriod(responses, minPeriodSec)
+	}
+	setCacheControl(w, spotIDs, bypassCache)
+	writeForecastResponse(w, r, responses)
+}
+
+// filterMinWaveHeight drops the forecasts with waves smaller than minWaveFt.
+// Entries that failed are kept so errors aren't silently hidden.
+func filterMinWaveHeight(responses []ForecastResponse, minWaveFt float64) []ForecastResponse {
+	filtered := make([]ForecastResponse, 0, len(responses))
+	for _, response := range responses {
+		threshold := minWaveFt
+		if response.Units == UNITS_METRIC {
+			threshold = ftToM(minWaveFt)
+		}
+		if response.Error != "" || response.WaveHeightFt >= threshold {
+			filtered = append(filtered, response)
+		}
+	}
+	return filtered
+}
+
+// filterMinSwellPeriod drops the forecasts whose primary swell period is
+// shorter than minPeriodSec. As with filterMinWaveHeight, failed entries are
+// kept.
+func filterMinSwellPeriod(responses []ForecastResponse, minPeriodSec int) []ForecastResponse {
+	filtered := make([]ForecastResponse, 0, len(responses))
+	for _, response := range responses {
+		if response.Error != "" || response.SwellPeriodSec >= minPeriodSec {
+			filtered = append(filtered, response)
+		}
+	}
+	return filtered
+}
+
+// getForecasts fetches a batch of spots. Batches return partial results,
+// flagging the spots that failed rather than failing the whole batch.
+//
+// Concurrent batches for the same set of spots and options share a single
+// assembly, whatever order they list the spots in.
+func getForecasts(ctx context.Context, spotIDs []string, bypassCache bool, units string) []ForecastResponse {
+	unique := make([]string, 0, len(spotIDs))
+	seen := make(map[string]bool, len(spotIDs))
+	for _, spotID := range spotIDs {
+		if !seen[spotID] {
+			seen[spotID] = true
+			unique = append(unique, spotID)
+		}
+	}
+	sort.Strings(unique)
+	key := strings.Join(unique, ",") + "|" + strconv.FormatBool(bypassCache) + "|" + units
+
+	result := batchGroup.DoChan(key, func() (interface{}, error) {
+		assembled := assembleForecasts(context.WithoutCancel(ctx), unique, bypassCache, units)
+		bySpot := make(map[string]ForecastResponse, len(assembled))
+		for _, response := range assembled {
+			bySpot[response.SpotID] = response
+		}
+		return bySpot, nil
+	})
+
+	var bySpot map[string]ForecastResponse
+	select {
+	case res := <-result:
+		bySpot = res.Val.(map[string]ForecastResponse)
+	case <-ctx.Done():
+		bySpot = make(map[string]ForecastResponse, len(unique))
+		for _, spotID := range unique {
+			location, _ := spotLocation(spotID)
+			bySpot[spotID] = ForecastResponse{SpotID: spotID, Location: location, Error: fetchErrorMessage(ctx.Err()), ApiVersion: API_VERSION}
+		}
+	}
+
+	// Each caller gets its own slice in the order it asked for
+	responses := make([]ForecastResponse, len(spotIDs))
+	for i, spotID := range spotIDs {
+		responses[i] = bySpot[spotID]
+	}
+	return responses
+}
+
+// Deduplicates concurrent identical batches, see getForecasts
+var batchGroup singleflight.Group
+
+// assembleForecasts fetches each of a batch's spots in turn
+func assembleForecasts(ctx context.Context, spotIDs []string, bypassCache bool, units string) []ForecastResponse {
+	responses := make([]ForecastResponse, 0, len(spotIDs))
+	for _, spotID := range spotIDs {
+		spot, ok := lookupSpot(ctx, spotID)
+		if !ok {
+			responses = append(responses, ForecastResponse{
+				SpotID:     spotID,
+				Location:   "Unknown Location",
+				Error:      "unknown spotId",
+				ApiVersion: API_VERSION,
+			})
+			continue
+		}
+
+		response, err := getForecast(ctx, spotID, bypassCache)
+		if err != nil {
+			slog.ErrorContext(ctx, "error fetching forecast", "event", "fetch_failed", "spotId", spotID, "error", err)
+			responses = append(responses, ForecastResponse{
+				SpotID:     spotID,
+				Location:   spot.Location,
+				Error:      fetchErrorMessage(err),
+				ApiVersion: API_VERSION,
+			})
+			continue
+		}
+		if units == UNITS_METRIC {
+			response = toMetric(response)
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+// handleForecastRange writes hourly forecasts for the window given by the
+// RFC3339 from and to parameters. Range forecasts are not cached.
+func handleForecastRange(w http.ResponseWriter, r *http.Request, spotID, units string) {
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid from parameter, expected an RFC3339 timestamp")
+		return
+	}
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid to parameter, expected an RFC3339 timestamp")
+		return
+	}
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "from must be before to")
+		return
+	}
+	if to.Sub(from) > MAX_FORECAST_RANGE {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Time range is too long")
+		return
+	}
+
+	if _, ok := lookupSpot(r.Context(), spotID); !ok {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spotId")
+		return
+	}
+	if disabledSpots[spotID] {
+		writeFetchError(w, errSpotDisabled)
+		return
+	}
+
+	rangeProvider, ok := forecastProvider.(ForecastRangeProvider)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, ERR_NOT_SUPPORTED, "Time ranges are not supported by this forecast source")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), fetchTimeout)
+	defer cancel()
+
+	responses, err := rangeProvider.FetchRange(ctx, spotID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching forecast range", "event", "fetch_failed", "spotId", spotID, "error", err)
+		writeFetchError(w, err)
+		return
+	}
+	for i := range responses {
+		enrichForecast(&responses[i])
+	}
+	if units == UNITS_METRIC {
+		for i := range responses {
+			responses[i] = toMetric(responses[i])
+		}
+	}
+	writeForecastResponse(w, r, responses)
+}
+
+// setCacheControl lets clients and proxies reuse a response for as long as
+// the soonest-expiring of its cache entries stays fresh. Requests that bypass
+// the cache, or are served stale, may not be reused. When REQUIRE_API_KEY is
+// on only the client may reuse it, so a shared proxy can't hand a response to
+// a caller without a key.
+func setCacheControl(w http.ResponseWriter, spotIDs []string, bypassCache bool) {
+	var maxAge int64
+	if !bypassCache {
+		now := appClock.Now().Unix()
+		for i, spotID := range spotIDs {
+			expiresAt, _ := forecastCache.ExpiresAt(spotID)
+			if remaining := max(expiresAt-now, 0); i == 0 || remaining < maxAge {
+				maxAge = remaining
+			}
+		}
+	}
+	scope := "public"
+	if apiKeyRequired {
+		scope = "private"
+	}
+	w.Header().Set("Cache-Control", scope+", max-age="+strconv.FormatInt(maxAge, 10))
+}
+
+// setCacheStatus reports in X-Cache whether a single forecast came from the
+// cache, see ForecastResponse.cacheStatus
+func setCacheStatus(w http.ResponseWriter, response ForecastResponse) {
+	if response.cacheStatus != "" {
+		w.Header().Set("X-Cache", response.cacheStatus)
+	}
+}
+
+// writeFetchError reports a failed provider fetch, using 504 Gateway Timeout
+// when the provider ran out of time, 503 Service Unavailable while the circuit
+// breaker is open or the spot is disabled and 502 Bad Gateway otherwise
+func writeFetchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errSpotDisabled):
+		writeJSONError(w, http.StatusServiceUnavailable, ERR_SPOT_DISABLED, "Forecasts for this spot are temporarily disabled")
+	case errors.Is(err, errCircuitOpen):
+		w.Header().Set("Retry-After", strconv.Itoa(int(breakerCooldown.Seconds())))
+		writeJSONError(w, http.StatusServiceUnavailable, ERR_UPSTREAM_UNAVAILABLE, "Forecast source unavailable, try again later")
+	case errors.Is(err, context.DeadlineExceeded):
+		writeJSONError(w, http.StatusGatewayTimeout, ERR_UPSTREAM_TIMEOUT, fetchErrorMessage(err))
+	case errors.Is(err, ErrUpstreamDecode):
+		writeJSONError(w, http.StatusBadGateway, ERR_UPSTREAM_ERROR, "Forecast source returned an invalid response")
+	default:
+		writeJSONError(w, http.StatusBadGateway, ERR_UPSTREAM_ERROR, "Failed to fetch forecast")
+	}
+}
+
+// fetchErrorMessage describes a failed provider fetch for batch entries
+func fetchErrorMessage(err error) string {
+	if errors.Is(err, errSpotDisabled) {
+		return "forecasts for this spot are temporarily disabled"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "forecast fetch timed out"
+	}
+	if errors.Is(err, ErrUpstreamDecode) {
+		return "forecast source returned an invalid response"
+	}
+	if errors.Is(err, errCircuitOpen) {
+		return "forecast source unavailable"
+	}
+	return "failed to fetch forecast"
+}
+
+// parseSpotIDs splits a comma-separated spotId parameter, dropping empty entries
+func parseSpotIDs(param string) []string {
+	var spotIDs []string
+	for _, spotID := range strings.Split(param, ",") {
+		spotID = strings.TrimSpace(spotID)
+		if spotID != "" {
+			spotIDs = append(spotIDs, spotID)
+		}
+	}
+	return spotIDs
+}
+
+// errSpotDisabled is returned for spots listed in DISABLED_SPOTS
+var errSpotDisabled = errors.New("spot is disabled")
+
+// getForecast returns the forecast for a spot, serving from cache when possible.
+// Disabled spots fail with errSpotDisabled without touching the cache or
+// provider.
+func getForecast(ctx context.Context, spotID string, bypassCache bool) (ForecastResponse, error) {
+	// Spots with known-bad upstream data are switched off by the operator
+	if disabledSpots[spotID] {
+		return ForecastResponse{}, errSpotDisabled
+	}
+
 	// Check cache first
-	now := time.Now().Unix()
+	now := appClock.Now().Unix()
 	if !bypassCache {
-		if cacheItem, ok := forecastCache[spotID]; ok && cacheItem.ExpiresAt > now {
-			log.Printf("Cache hit for spot ID: %s", spotID)
-			json.NewEncoder(w).Encode(cacheItem.Response)
-			return
+		cached, fresh, found := forecastCache.Lookup(spotID)
+		if fresh {
+			slog.DebugContext(ctx, "cache hit", "event", "cache_hit", "spotId", spotID, "cache", "hit")
+			forecastRequestsTotal.WithLabelValues(spotID, "hit").Inc()
+			cached.cacheStatus = "hit"
+			return cached, nil
+		}
+		if found {
+			// Serve the expired copy now and refresh it for the next caller
+			slog.DebugContext(ctx, "serving stale data", "event", "cache_stale", "spotId", spotID, "cache", "stale")
+			forecastRequestsTotal.WithLabelValues(spotID, "stale").Inc()
+			revalidate(spotID)
+			cached.Stale = true
+			cached.cacheStatus = "stale"
+			return cached, nil
 		}
 	}
 	
-	log.Printf("Fetching fresh data for spot ID: %s", spotID)
-	
-	// For now, we'll return mock data since we're not actually connecting to Surfline yet
-	// In a real implementation, you would use the surflinef library here
-	response := getMockForecastResponse(spotID)
-	
-	// Cache the response
-	forecastCache[spotID] = CacheItem{
-		Response:  response,
-		ExpiresAt: now + CACHE_DURATION,
+	cacheStatus := "miss"
+	if bypassCache {
+		cacheStatus = "bypass"
 	}
+	slog.DebugContext(ctx, "fetching fresh data", "event", "fetch", "spotId", spotID, "cache", cacheStatus)
+	forecastRequestsTotal.WithLabelValues(spotID, cacheStatus).Inc()
 	
-	// Return the response
-	json.NewEncoder(w).Encode(response)
+	// Concurrent misses for a spot share a single upstream fetch. The fetch is
+	// detached from the first caller's cancellation so one client hanging up
+	// doesn't fail everyone waiting on it.
+	result := fetchGroup.DoChan(spotID, func() (interface{}, error) {
+		if err := providerBreaker.Allow(); err != nil {
+			return ForecastResponse{}, err
+		}
+		response, err := fetchWithRetry(context.WithoutCancel(ctx), forecastProvider, spotID, fetchRetries)
+		providerBreaker.Record(err)
+		if err != nil {
+			return ForecastResponse{}, err
+		}
+		enrichForecast(&response)
+		response.Trend = computeTrend(response.WaveHeightFt, recentWaveHeights(forecastHistory.Get(spotID), TREND_WINDOW))
+		forecastHistory.Add(spotID, response)
+
+		// Cache the response
+		expiresAt := now + cacheTTL(spotID)
+		response.FreshUntil = expiresAt
+		forecastCache.Set(spotID, response, expiresAt)
+
+		return response, nil
+	})
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return ForecastResponse{}, res.Err
+		}
+		response := res.Val.(ForecastResponse)
+		response.cacheStatus = cacheStatus
+		return response, nil
+	case <-ctx.Done():
+		return ForecastResponse{}, ctx.Err()
+	}
+}
+
+// Deduplicates concurrent upstream fetches for the same spot
+var fetchGroup singleflight.Group
+
+// Spots with a background refresh in flight
+var revalidating sync.Map
+
+// revalidate refetches a spot in the background, unless a refresh for it is
+// already running
+func revalidate(spotID string) {
+	if _, running := revalidating.LoadOrStore(spotID, true); running {
+		return
+	}
+	go func() {
+		defer revalidating.Delete(spotID)
+		if _, err := getForecast(context.Background(), spotID, true); err != nil {
+			slog.Warn("background revalidation failed", "event", "revalidate_failed", "spotId", spotID, "error", err)
+		}
+	}()
 }
 
+// Smaller background swell trains mixed into each mock spot's primary swell
+var mockSecondarySwells = map[string]Swell{
+	"5842041f4e65fad6a7708814": {HeightFt: 1.2, PeriodSec: 8, DirectionDeg: 270},  // Malibu
+	"5842041f4e65fad6a770883d": {HeightFt: 1.0, PeriodSec: 7, DirectionDeg: 280},  // Huntington
+	"5842041f4e65fad6a7709115": {HeightFt: 1.5, PeriodSec: 9, DirectionDeg: 190},  // Tamarindo
+	"5842041f4e65fad6a7709117": {HeightFt: 1.1, PeriodSec: 8, DirectionDeg: 180},  // Jaco
+	"5842041f4e65fad6a7709116": {HeightFt: 1.6, PeriodSec: 10, DirectionDeg: 195}, // Dominical
+}
+
+// mockForecast builds a spot's canned forecast. It's a variable so tests can
+// swap in mock data the parsers don't understand.
+var mockForecast = getMockForecastResponse
+
 func getMockForecastResponse(spotID string) ForecastResponse {
 	// Get the location name
-	location, ok := spotLocations[spotID]
+	location, ok := spotLocation(spotID)
 	if !ok {
 		location = "Unknown Location"
 	}
 	
+	now := appClock.Now()
+
 	// Create mock data based on the spot ID
 	var waveHeight, windSpeed, windDirection, tide string
+	var windDegrees int
+	var windGustMph, waterTempF, airTempF float64
 	
 	switch spotID {
 	case "5842041f4e65fad6a7708814": // Malibu
-		waveHeight = "3.8 ft at 12 seconds 215 degrees"
+		waveHeight = "3-4.5 ft at 12 seconds 215 degrees"
 		windSpeed = "5 mph"
 		windDirection = "Offshore"
+		windDegrees = 10
+		windGustMph = 8
 		tide = "Rising, 2.5ft at 10:30am"
+		waterTempF = 62
+		airTempF = 68
 	case "5842041f4e65fad6a770883d": // Huntington
-		waveHeight = "2.5 ft at 10 seconds 220 degrees"
+		waveHeight = "2-3 ft at 10 seconds 220 degrees"
 		windSpeed = "8 mph"
 		windDirection = "Cross-shore"
+		windDegrees = 300
+		windGustMph = 13
 		tide = "Falling, 3.2ft at 9:15am"
+		waterTempF = 64
+		airTempF = 72
 	case "5842041f4e65fad6a7709115": // Tamarindo
-		waveHeight = "4.5 ft at 14 seconds 210 degrees"
+		waveHeight = "4-5 ft at 14 seconds 210 degrees"
 		windSpeed = "3 mph"
 		windDirection = "Offshore"
+		windDegrees = 90
+		windGustMph = 5
 		tide = "High, 4.1ft at 11:45am"
+		waterTempF = 84
+		airTempF = 88
 	case "5842041f4e65fad6a7709117": // Jaco
-		waveHeight = "3.7 ft at 12 seconds 205 degrees"
+		waveHeight = "3-4.5 ft at 12 seconds 205 degrees"
 		windSpeed = "6 mph"
 		windDirection = "Offshore"
+		windDegrees = 45
+		windGustMph = 9
 		tide = "Low, 1.2ft at 8:30am"
+		waterTempF = 83
+		airTempF = 86
 	case "5842041f4e65fad6a7709116": // Dominical
-		waveHeight = "5.2 ft at 16 seconds 207 degrees"
+		waveHeight = "4.5-6 ft at 16 seconds 207 degrees"
 		windSpeed = "4 mph"
 		windDirection = "Offshore"
+		windDegrees = 40
+		windGustMph = 6
 		tide = "Mid, 2.8ft at 9:45am"
+		waterTempF = 82
+		airTempF = 85
 	default:
 		waveHeight = "Unknown"
 		windSpeed = "Unknown"
@@ -149,13 +919,56 @@ func getMockForecastResponse(spotID string) ForecastResponse {
 		tide = "Unknown"
 	}
 	
-	return ForecastResponse{
+	response := ForecastResponse{
 		SpotID:         spotID,
 		Location:       location,
 		WaveHeight:     waveHeight,
 		WindSpeed:      windSpeed,
 		WindDirection:  windDirection,
 		Tide:           tide,
-		Timestamp:      time.Now().Unix(),
+		Timestamp:      now.Unix(),
+		WindDegrees:    windDegrees,
+		WindGustMph:    windGustMph,
+		WaterTempF:     waterTempF,
+		AirTempF:       airTempF,
+		TideEvents:     mockTideEvents(spotID, now),
+		Units:          UNITS_IMPERIAL,
+		Source:         SOURCE_MOCK,
+	}
+
+	if spot, ok := knownSpots.Get(spotID); ok {
+		response.UVIndex = mockUVIndex(spot.Lat, spot.Lon, now)
+	}
+
+	// Unknown spots have no numeric data, so leave the parsed fields zeroed
+	if minFt, maxFt, periodSec, directionDeg, err := parseWaveHeight(waveHeight); err == nil {
+		heightFt := math.Round((minFt+maxFt)/2*10) / 10
+		response.WaveHeightFt = heightFt
+		response.WaveHeightMinFt = minFt
+		response.WaveHeightMaxFt = maxFt
+		response.SwellPeriodSec = periodSec
+		response.SwellDirectionDeg = directionDeg
+		response.Swells = []Swell{{HeightFt: heightFt, PeriodSec: periodSec, DirectionDeg: directionDeg}}
+		if secondary, ok := mockSecondarySwells[spotID]; ok {
+			response.Swells = append(response.Swells, secondary)
+		}
+	}
+
+	return response
+}
+
+// validateMockData checks that the parsers still understand every built-in
+// spot's mock output, so a format change is caught at startup rather than
+// showing up as zeroed fields
+func validateMockData() error {
+	for _, spot := range defaultSpots {
+		response := mockForecast(spot.SpotID)
+		if _, _, _, _, err := parseWaveHeight(response.WaveHeight); err != nil {
+			return fmt.Errorf("mock data for %s: %w", spot.SpotID, err)
+		}
+		if _, err := parseWindSpeed(response.WindSpeed); err != nil {
+			return fmt.Errorf("mock data for %s: %w", spot.SpotID, err)
+		}
 	}
+	return nil
 }
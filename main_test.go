@@ -0,0 +1,1095 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Spot IDs used throughout the tests
+const (
+	malibu     = "5842041f4e65fad6a7708814"
+	huntington = "5842041f4e65fad6a770883d"
+	tamarindo  = "5842041f4e65fad6a7709115"
+
+	unknownSpotID = "000000000000000000000000"
+)
+
+// setForTest sets *target to value for the rest of the test
+func setForTest[T any](t *testing.T, target *T, value T) {
+	t.Helper()
+	previous := *target
+	*target = value
+	t.Cleanup(func() { *target = previous })
+}
+
+// useCache gives the test an empty forecast cache of its own
+func useCache(t *testing.T) *forecastCacheStore {
+	t.Helper()
+	setForTest(t, &forecastCache, newForecastCacheStore(DEFAULT_CACHE_MAX_ENTRIES, 0))
+	return forecastCache
+}
+
+// errorCode returns the machine-readable code of a JSON error response
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error body %q: %v", w.Body, err)
+	}
+	return body["code"]
+}
+
+func TestHandleForecast(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		want     int
+		wantErr  string
+		wantCode string
+	}{
+		{"known spot", "/forecast?spotId=" + malibu, http.StatusOK, "", ""},
+		{"bypass cache", "/forecast?spotId=" + malibu + "&bypassCache=true", http.StatusOK, "", ""},
+		{"missing spot", "/forecast", http.StatusBadRequest, "Missing spotId parameter", ERR_MISSING_SPOT_ID},
+		{"unknown spot", "/forecast?spotId=" + unknownSpotID, http.StatusNotFound, "unknown spotId", ERR_UNKNOWN_SPOT},
+		{"by slug", "/forecast?spot=malibu", http.StatusOK, "", ""},
+		{"slug ignores case", "/forecast?spot=Malibu", http.StatusOK, "", ""},
+		{"unknown slug", "/forecast?spot=narnia", http.StatusNotFound, "unknown spot", ERR_UNKNOWN_SPOT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCache(t)
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantErr != "" {
+				if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+				if body["error"] != tt.wantErr || body["code"] != tt.wantCode {
+					t.Errorf("error = %q (%s), want %q (%s)", body["error"], body["code"], tt.wantErr, tt.wantCode)
+				}
+				return
+			}
+			var response ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if response.SpotID != malibu || response.Location != "Malibu, CA" {
+				t.Errorf("response = %+v", response)
+			}
+		})
+	}
+}
+
+func TestHandleForecastDefaultSpot(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        string
+		url        string
+		want       int
+		wantSpotID string
+	}{
+		{"configured default", huntington, "/forecast", http.StatusOK, huntington},
+		{"explicit spot wins", huntington, "/forecast?spotId=" + malibu, http.StatusOK, malibu},
+		{"no default", "", "/forecast", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_SPOT_ID", tt.env)
+			setForTest(t, &defaultSpotID, defaultSpotID)
+			useCache(t)
+			loadConfig()
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantSpotID == "" {
+				return
+			}
+			var response ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if response.SpotID != tt.wantSpotID {
+				t.Errorf("SpotID = %q, want %q", response.SpotID, tt.wantSpotID)
+			}
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		method    string
+		path      string
+		wantAllow string
+	}{
+		{http.MethodPost, "/forecast?spotId=" + malibu, "GET, HEAD"},
+		{http.MethodDelete, "/v1/forecast?spotId=" + malibu, "GET, HEAD"},
+		{http.MethodPut, "/forecast/best", "GET"},
+		{http.MethodGet, "/forecast/batch", "POST"},
+		{http.MethodPost, "/forecast/compare?a=" + malibu + "&b=" + huntington, "GET"},
+		{http.MethodPost, "/forecast/summary?spotId=" + malibu, "GET"},
+		{http.MethodPost, "/forecast/digest?region=CR", "GET"},
+		{http.MethodPost, "/forecast/watch?spotId=" + malibu, "GET"},
+		{http.MethodPost, "/forecast/history?spotId=" + malibu, "GET"},
+		{http.MethodPost, "/forecast/nearest?lat=34&lon=-118", "GET"},
+		{http.MethodPost, "/forecast/byurl?url=https://www.surfline.com/surf-report/malibu/" + malibu, "GET"},
+		{http.MethodPost, "/favorites/forecast?user=kai", "GET"},
+		{http.MethodDelete, "/favorites?user=kai", "GET, PUT"},
+		{http.MethodPut, "/alerts", "GET, POST, DELETE"},
+		{http.MethodPost, "/spots/search?q=malibu", "GET"},
+		{http.MethodPut, "/spots/" + malibu, "GET"},
+		{http.MethodPost, "/regions", "GET"},
+		{http.MethodPost, "/cache/stats", "GET"},
+		{http.MethodPost, "/health", "GET, HEAD"},
+		{http.MethodPost, "/ready", "GET"},
+		{http.MethodPost, "/version", "GET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			provider := newFakeProvider()
+			useProvider(t, provider)
+			setForTest(t, &rateLimitPerMin, 0)
+
+			w := httptest.NewRecorder()
+			newHandler().ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("status = %d, want 405: %s", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] != "Method not allowed" || body["code"] != ERR_METHOD_NOT_ALLOWED {
+				t.Errorf("body = %q, want a JSON error", w.Body)
+			}
+			if got := provider.Calls(malibu); got != 0 {
+				t.Errorf("rejected request fetched %d forecasts", got)
+			}
+		})
+	}
+}
+
+func TestHeadRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		url     string
+	}{
+		{"forecast", handleForecast, "/forecast?spotId=" + malibu},
+		{"health", handleHealth, "/health"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, newFakeProvider())
+			get := httptest.NewRecorder()
+			tt.handler(get, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			head := httptest.NewRecorder()
+			tt.handler(head, httptest.NewRequest(http.MethodHead, tt.url, nil))
+
+			if head.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", head.Code, http.StatusOK)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD returned a %d byte body", head.Body.Len())
+			}
+			for _, header := range []string{"Content-Type", "Content-Length", "ETag"} {
+				if got, want := head.Header().Get(header), get.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q as on GET", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetForecastCaching(t *testing.T) {
+	tests := []struct {
+		name        string
+		bypassCache []bool
+		wantCalls   int
+		wantStatus  string
+	}{
+		{"miss fetches", []bool{false}, 1, "miss"},
+		{"hit is served from cache", []bool{false, false}, 1, "hit"},
+		{"bypass refetches", []bool{false, true}, 2, "bypass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			useProvider(t, provider)
+
+			var response ForecastResponse
+			for _, bypass := range tt.bypassCache {
+				var err error
+				if response, err = getForecast(context.Background(), malibu, bypass); err != nil {
+					t.Fatalf("getForecast() error = %v", err)
+				}
+			}
+			if got := provider.Calls(malibu); got != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", got, tt.wantCalls)
+			}
+			if response.cacheStatus != tt.wantStatus {
+				t.Errorf("cacheStatus = %q, want %q", response.cacheStatus, tt.wantStatus)
+			}
+			// A cached copy still says where it came from
+			if response.Source != SOURCE_LIVE {
+				t.Errorf("Source = %q, want %q", response.Source, SOURCE_LIVE)
+			}
+		})
+	}
+}
+
+func TestGetForecastFreshUntil(t *testing.T) {
+	for _, bypass := range []bool{false, true} {
+		t.Run("bypassCache="+strconv.FormatBool(bypass), func(t *testing.T) {
+			useProvider(t, newFakeProvider())
+			cache := useCache(t)
+
+			before := time.Now().Unix()
+			response, err := getForecast(context.Background(), malibu, bypass)
+			after := time.Now().Unix()
+			if err != nil {
+				t.Fatalf("getForecast() error = %v", err)
+			}
+
+			expiresAt := cache.items[malibu].Value.(*cacheEntry).item.ExpiresAt
+			if response.FreshUntil != expiresAt {
+				t.Errorf("FreshUntil = %d, want the cached ExpiresAt %d", response.FreshUntil, expiresAt)
+			}
+			ttl := cacheTTL(malibu)
+			if response.FreshUntil < before+ttl || response.FreshUntil > after+ttl {
+				t.Errorf("FreshUntil = %d, want %d seconds from now", response.FreshUntil, ttl)
+			}
+
+			// A cache hit reports the same expiry it was stored with
+			cached, err := getForecast(context.Background(), malibu, false)
+			if err != nil {
+				t.Fatalf("getForecast() error = %v", err)
+			}
+			if cached.FreshUntil != expiresAt {
+				t.Errorf("cached FreshUntil = %d, want %d", cached.FreshUntil, expiresAt)
+			}
+		})
+	}
+}
+
+func TestGetForecastMockSource(t *testing.T) {
+	useProvider(t, mockProvider{})
+	for i := 0; i < 2; i++ {
+		response, err := getForecast(context.Background(), malibu, false)
+		if err != nil {
+			t.Fatalf("getForecast() error = %v", err)
+		}
+		if response.Source != SOURCE_MOCK {
+			t.Errorf("Source = %q, want %q", response.Source, SOURCE_MOCK)
+		}
+		// Made-up data credits nobody
+		if response.Attribution != "" {
+			t.Errorf("Attribution = %q, want none", response.Attribution)
+		}
+	}
+}
+
+func TestHandleForecastDisabledSpot(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"single spot", "/forecast?spotId=" + malibu, http.StatusServiceUnavailable},
+		{"enabled spot", "/forecast?spotId=" + huntington, http.StatusOK},
+		{"range", "/forecast?spotId=" + malibu + "&from=2024-06-01T00:00:00Z&to=2024-06-01T03:00:00Z", http.StatusServiceUnavailable},
+		{"batch reports per spot", "/forecast?spotId=" + malibu + "," + huntington, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			useProvider(t, provider)
+			setForTest(t, &disabledSpots, map[string]bool{malibu: true})
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if provider.Calls(malibu) != 0 {
+				t.Error("disabled spot reached the provider")
+			}
+			if tt.want != http.StatusOK {
+				if got := errorCode(t, w); got != ERR_SPOT_DISABLED {
+					t.Errorf("code = %q, want %q", got, ERR_SPOT_DISABLED)
+				}
+				return
+			}
+			if !strings.Contains(tt.url, ",") {
+				if got := provider.Calls(huntington); got != 1 {
+					t.Errorf("enabled spot fetched %d times, want 1", got)
+				}
+				return
+			}
+			var responses []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if responses[0].Error == "" || responses[1].Error != "" {
+				t.Errorf("errors = %q, %q, want only the disabled spot to fail", responses[0].Error, responses[1].Error)
+			}
+		})
+	}
+}
+
+func TestHandleForecastCacheHeaders(t *testing.T) {
+	useProvider(t, newFakeProvider())
+	url := "/forecast?spotId=" + malibu
+
+	var etags []string
+	for _, want := range []string{"miss", "hit"} {
+		w := httptest.NewRecorder()
+		handleForecast(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if got := w.Header().Get("X-Cache"); got != want {
+			t.Errorf("X-Cache = %q, want %q", got, want)
+		}
+		etags = append(etags, w.Header().Get("ETag"))
+	}
+	if etags[0] != etags[1] {
+		t.Errorf("ETag changed from %s to %s on a cache hit", etags[0], etags[1])
+	}
+}
+
+func TestGetForecastSingleflight(t *testing.T) {
+	provider := newFakeProvider()
+	provider.hold = make(chan struct{})
+	useProvider(t, provider)
+
+	const callers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := getForecast(context.Background(), malibu, false)
+			errs <- err
+		}()
+	}
+	// Let every caller reach the in-flight fetch before it completes
+	for provider.Calls(malibu) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(provider.hold)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("getForecast() error = %v", err)
+		}
+	}
+	if got := provider.Calls(malibu); got != 1 {
+		t.Errorf("provider called %d times for %d concurrent misses, want 1", got, callers)
+	}
+}
+
+// Run with -race: concurrent hits, misses and bypasses all share the cache
+func TestConcurrentForecastRequests(t *testing.T) {
+	useCache(t)
+
+	spotIDs := []string{malibu, huntington, tamarindo}
+	const requests = 100
+	var wg sync.WaitGroup
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url := "/forecast?spotId=" + spotIDs[i%len(spotIDs)]
+			if i%4 == 0 {
+				url += "&bypassCache=true"
+			}
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, url, nil))
+			codes <- w.Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("status = %d, want %d", code, http.StatusOK)
+		}
+	}
+	for _, spotID := range spotIDs {
+		if _, ok := forecastCache.Get(spotID); !ok {
+			t.Errorf("%s was not cached", spotID)
+		}
+	}
+}
+
+func TestForecastBatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		spotIDs       []string
+		wantLocations []string
+		wantErrors    []string
+	}{
+		{"three spots", []string{malibu, huntington, tamarindo}, []string{"Malibu, CA", "Huntington Beach, CA", "Tamarindo, CR"}, []string{"", "", ""}},
+		{"known and unknown", []string{malibu, unknownSpotID}, []string{"Malibu, CA", "Unknown Location"}, []string{"", "unknown spotId"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCache(t)
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+strings.Join(tt.spotIDs, ","), nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			var responses []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if len(responses) != len(tt.spotIDs) {
+				t.Fatalf("got %d forecasts, want %d", len(responses), len(tt.spotIDs))
+			}
+			for i, response := range responses {
+				if response.SpotID != tt.spotIDs[i] || response.Location != tt.wantLocations[i] {
+					t.Errorf("forecast %d = %s %q, want %s %q", i, response.SpotID, response.Location, tt.spotIDs[i], tt.wantLocations[i])
+				}
+				if response.Error != tt.wantErrors[i] {
+					t.Errorf("forecast %d error = %q, want %q", i, response.Error, tt.wantErrors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestForecastBatchMinWaveFt(t *testing.T) {
+	spots := malibu + "," + huntington + "," + tamarindo + "," + unknownSpotID
+	tests := []struct {
+		name      string
+		query     string
+		want      int
+		wantSpots []string
+	}{
+		{"excludes small spots", "&minWaveFt=3", http.StatusOK, []string{huntington, tamarindo, unknownSpotID}},
+		{"includes every spot", "&minWaveFt=1", http.StatusOK, []string{malibu, huntington, tamarindo, unknownSpotID}},
+		{"metric threshold stays in feet", "&minWaveFt=4.5&units=metric", http.StatusOK, []string{huntington, unknownSpotID}},
+		{"no threshold", "", http.StatusOK, []string{malibu, huntington, tamarindo, unknownSpotID}},
+		{"negative", "&minWaveFt=-1", http.StatusBadRequest, nil},
+		{"not a number", "&minWaveFt=big", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, heightsProvider(map[string]float64{malibu: 2, huntington: 5, tamarindo: 4}))
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+spots+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var responses []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			var got []string
+			for _, response := range responses {
+				got = append(got, response.SpotID)
+			}
+			// Failed spots are kept so their errors aren't hidden
+			if strings.Join(got, ",") != strings.Join(tt.wantSpots, ",") {
+				t.Errorf("spots = %v, want %v", got, tt.wantSpots)
+			}
+		})
+	}
+}
+
+func TestForecastBatchMinPeriodSec(t *testing.T) {
+	spots := malibu + "," + huntington + "," + tamarindo + "," + unknownSpotID
+	tests := []struct {
+		name      string
+		query     string
+		want      int
+		wantSpots []string
+	}{
+		{"excludes short periods", "&minPeriodSec=10", http.StatusOK, []string{malibu, tamarindo, unknownSpotID}},
+		{"period threshold met exactly", "&minPeriodSec=14", http.StatusOK, []string{malibu, unknownSpotID}},
+		{"wave height alone", "&minWaveFt=3", http.StatusOK, []string{huntington, tamarindo, unknownSpotID}},
+		{"both filters", "&minWaveFt=3&minPeriodSec=10", http.StatusOK, []string{tamarindo, unknownSpotID}},
+		{"both filters exclude everything", "&minWaveFt=6&minPeriodSec=10", http.StatusOK, []string{unknownSpotID}},
+		{"negative", "&minPeriodSec=-1", http.StatusBadRequest, nil},
+		{"not a number", "&minPeriodSec=long", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Malibu is small but long-period, Huntington big wind swell
+			swells := map[string][2]float64{malibu: {2, 14}, huntington: {5, 8}, tamarindo: {4, 12}}
+			provider := newFakeProvider()
+			provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+				swell, ok := swells[spotID]
+				if !ok {
+					return ForecastResponse{}, errors.New("upstream unavailable")
+				}
+				response := fakeForecast(spotID, swell[0])
+				response.WaveHeight = fmt.Sprintf("%g ft at %g seconds 210 degrees", swell[0], swell[1])
+				response.SwellPeriodSec = int(swell[1])
+				return response, nil
+			}
+			useProvider(t, provider)
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+spots+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				if got := errorCode(t, w); got != ERR_INVALID_PARAMETER {
+					t.Errorf("code = %q, want %q", got, ERR_INVALID_PARAMETER)
+				}
+				return
+			}
+			var responses []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			var got []string
+			for _, response := range responses {
+				got = append(got, response.SpotID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantSpots, ",") {
+				t.Errorf("spots = %v, want %v", got, tt.wantSpots)
+			}
+		})
+	}
+}
+
+func TestParseSpotIDs(t *testing.T) {
+	tests := []struct {
+		param string
+		want  []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{" a , b ,,", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			got := parseSpotIDs(tt.param)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("parseSpotIDs(%q) = %q, want %q", tt.param, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleForecastDelegatesToProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		fetchErr  error
+		want      int
+		wantCalls int
+	}{
+		{"served from the provider", nil, http.StatusOK, 1},
+		{"provider fails", errors.New("upstream unavailable"), http.StatusBadGateway, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+				if tt.fetchErr != nil {
+					return ForecastResponse{}, tt.fetchErr
+				}
+				return fakeForecast(spotID, 6.5), nil
+			}
+			useProvider(t, provider)
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if got := provider.Calls(malibu); got != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", got, tt.wantCalls)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var response ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if response.WaveHeightFt != 6.5 {
+				t.Errorf("WaveHeightFt = %v, want the provider's 6.5", response.WaveHeightFt)
+			}
+		})
+	}
+}
+
+func TestServeShutsDownOnSignal(t *testing.T) {
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGINT} {
+		t.Run(sig.String(), func(t *testing.T) {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, sig)
+			defer signal.Stop(signals)
+
+			ready.Store(true)
+			server := &http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()}
+			done := make(chan error, 1)
+			go func() { done <- serve(server, signals) }()
+
+			if err := syscall.Kill(os.Getpid(), sig); err != nil {
+				t.Fatalf("sending %s: %v", sig, err)
+			}
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("serve() = %v, want nil after a clean shutdown", err)
+				}
+			case <-time.After(SHUTDOWN_TIMEOUT):
+				t.Fatal("serve() did not return after the signal")
+			}
+			if ready.Load() {
+				t.Error("still ready after shutting down")
+			}
+		})
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	tests := []struct {
+		name                     string
+		env                      map[string]string
+		wantReadHeader, wantRead time.Duration
+		wantWrite, wantIdle      time.Duration
+	}{
+		{"defaults", nil, 5 * time.Second, 10 * time.Second, 90 * time.Second, 120 * time.Second},
+		{"configured", map[string]string{
+			"READ_HEADER_TIMEOUT_SECONDS": "2",
+			"READ_TIMEOUT_SECONDS":        "3",
+			"WRITE_TIMEOUT_SECONDS":       "4",
+			"IDLE_TIMEOUT_SECONDS":        "5",
+		}, 2 * time.Second, 3 * time.Second, 4 * time.Second, 5 * time.Second},
+		{"invalid", map[string]string{"WRITE_TIMEOUT_SECONDS": "forever"}, 5 * time.Second, 10 * time.Second, 90 * time.Second, 120 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			setForTest(t, &readHeaderTimeout, readHeaderTimeout)
+			setForTest(t, &readTimeout, readTimeout)
+			setForTest(t, &writeTimeout, writeTimeout)
+			setForTest(t, &idleTimeout, idleTimeout)
+			loadConfig()
+
+			handler := http.NotFoundHandler()
+			server := newServer("127.0.0.1:0", handler)
+			if server.Addr != "127.0.0.1:0" || server.Handler == nil {
+				t.Errorf("Addr, Handler = %q, %v", server.Addr, server.Handler)
+			}
+			if server.ReadHeaderTimeout != tt.wantReadHeader || server.ReadTimeout != tt.wantRead || server.WriteTimeout != tt.wantWrite || server.IdleTimeout != tt.wantIdle {
+				t.Errorf("timeouts = %v, %v, %v, %v, want %v, %v, %v, %v",
+					server.ReadHeaderTimeout, server.ReadTimeout, server.WriteTimeout, server.IdleTimeout,
+					tt.wantReadHeader, tt.wantRead, tt.wantWrite, tt.wantIdle)
+			}
+		})
+	}
+
+	// Long polls must fit within the write timeout
+	if time.Duration(DEFAULT_WRITE_TIMEOUT_SECONDS)*time.Second <= WATCH_TIMEOUT {
+		t.Errorf("default write timeout %ds cuts off /forecast/watch after %v", DEFAULT_WRITE_TIMEOUT_SECONDS, WATCH_TIMEOUT)
+	}
+}
+
+func TestHandleReady(t *testing.T) {
+	for _, tt := range []struct {
+		ready bool
+		want  int
+	}{{false, http.StatusServiceUnavailable}, {true, http.StatusOK}} {
+		t.Run(fmt.Sprint(tt.ready), func(t *testing.T) {
+			previous := ready.Load()
+			ready.Store(tt.ready)
+			t.Cleanup(func() { ready.Store(previous) })
+
+			w := httptest.NewRecorder()
+			handleReady(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleForecastRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  ForecastProvider
+		query     string
+		want      int
+		wantHours int
+	}{
+		{"six hour window", mockProvider{}, "from=2024-06-01T00:00:00Z&to=2024-06-01T06:00:00Z", http.StatusOK, 6},
+		{"invalid from", mockProvider{}, "from=yesterday&to=2024-06-01T03:00:00Z", http.StatusBadRequest, 0},
+		{"missing to", mockProvider{}, "from=2024-06-01T00:00:00Z", http.StatusBadRequest, 0},
+		{"backwards", mockProvider{}, "from=2024-06-01T03:00:00Z&to=2024-06-01T00:00:00Z", http.StatusBadRequest, 0},
+		{"too long", mockProvider{}, "from=2024-06-01T00:00:00Z&to=2024-06-09T00:00:00Z", http.StatusBadRequest, 0},
+		{"provider without ranges", newFakeProvider(), "from=2024-06-01T00:00:00Z&to=2024-06-01T03:00:00Z", http.StatusNotImplemented, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, tt.provider)
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+"&"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var responses []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if len(responses) != tt.wantHours {
+				t.Errorf("got %d hours, want %d", len(responses), tt.wantHours)
+			}
+		})
+	}
+}
+
+func TestHandleForecastTimeout(t *testing.T) {
+	setForTest(t, &fetchTimeout, 20*time.Millisecond)
+	cancelled := make(chan error, 1)
+	provider := newFakeProvider()
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ForecastResponse{}, ctx.Err()
+	}
+	useProvider(t, provider)
+
+	w := httptest.NewRecorder()
+	handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu, nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusGatewayTimeout, w.Body)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	if body["error"] != "forecast fetch timed out" {
+		t.Errorf("error = %q, want %q", body["error"], "forecast fetch timed out")
+	}
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("provider context error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestHandleHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		fetchErr   error
+		want       int
+		wantStatus string
+		wantCalls  int
+	}{
+		{"shallow", "/health", errors.New("down"), http.StatusOK, "ok", 0},
+		{"deep ok", "/health?deep=true", nil, http.StatusOK, "ok", 1},
+		{"deep degraded", "/health?deep=true", errors.New("down"), http.StatusServiceUnavailable, "degraded", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+				return fakeForecast(spotID, 3), tt.fetchErr
+			}
+			useProvider(t, provider)
+
+			w := httptest.NewRecorder()
+			handleHealth(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if body["status"] != tt.wantStatus {
+				t.Errorf("status field = %q, want %q", body["status"], tt.wantStatus)
+			}
+			calls := 0
+			for _, spot := range listSpots() {
+				calls += provider.Calls(spot.SpotID)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("made %d provider calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestGetForecastDisabledSpot(t *testing.T) {
+	tests := []struct {
+		name   string
+		cached bool
+		bypass bool
+	}{
+		{"uncached", false, false},
+		{"cached copy is not served", true, false},
+		{"bypass", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			useProvider(t, provider)
+			setForTest(t, &disabledSpots, map[string]bool{malibu: true})
+			if tt.cached {
+				forecastCache.Set(malibu, fakeForecast(malibu, 3), time.Now().Unix()+60)
+			}
+
+			_, err := getForecast(context.Background(), malibu, tt.bypass)
+			if !errors.Is(err, errSpotDisabled) {
+				t.Errorf("getForecast() error = %v, want errSpotDisabled", err)
+			}
+			if got := provider.Calls(malibu); got != 0 {
+				t.Errorf("provider called %d times for a disabled spot", got)
+			}
+		})
+	}
+}
+
+func TestGetForecastStale(t *testing.T) {
+	provider := newFakeProvider()
+	useProvider(t, provider)
+	setForTest(t, &forecastCache, newForecastCacheStore(DEFAULT_CACHE_MAX_ENTRIES, 60))
+	forecastCache.Set(malibu, fakeForecast(malibu, 2), time.Now().Unix()-30)
+
+	response, err := getForecast(context.Background(), malibu, false)
+	if err != nil {
+		t.Fatalf("getForecast() error = %v", err)
+	}
+	if !response.Stale || response.cacheStatus != "stale" || response.WaveHeightFt != 2 {
+		t.Errorf("Stale = %v, cacheStatus = %q, WaveHeightFt = %v, want the stale 2ft copy", response.Stale, response.cacheStatus, response.WaveHeightFt)
+	}
+
+	// The stale copy triggers a background refresh
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, running := revalidating.Load(malibu)
+		if provider.Calls(malibu) == 1 && !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale copy was not revalidated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if refreshed, ok := forecastCache.Get(malibu); !ok || refreshed.Stale {
+		t.Errorf("cache holds %+v, %v after revalidation, want a fresh copy", refreshed, ok)
+	}
+}
+
+func TestMockForecastSwells(t *testing.T) {
+	for _, spot := range defaultSpots {
+		t.Run(spot.Location, func(t *testing.T) {
+			resp := getMockForecastResponse(spot.SpotID)
+			if len(resp.Swells) == 0 {
+				t.Fatal("no swells")
+			}
+			if resp.Swells[0].HeightFt != resp.WaveHeightFt || resp.Swells[0].PeriodSec != resp.SwellPeriodSec {
+				t.Errorf("primary swell %+v does not match the wave height", resp.Swells[0])
+			}
+			for _, swell := range resp.Swells {
+				if swell.HeightFt <= 0 || swell.HeightFt > 30 || swell.PeriodSec < 4 || swell.PeriodSec > 25 || swell.DirectionDeg < 0 || swell.DirectionDeg >= 360 {
+					t.Errorf("implausible swell %+v", swell)
+				}
+			}
+		})
+	}
+}
+
+func TestMockForecastGusts(t *testing.T) {
+	from := time.Now()
+	for _, spot := range defaultSpots {
+		t.Run(spot.Location, func(t *testing.T) {
+			hourly, err := mockProvider{}.FetchRange(context.Background(), spot.SpotID, from, from.Add(48*time.Hour))
+			if err != nil {
+				t.Fatalf("FetchRange() error = %v", err)
+			}
+			for _, resp := range append(hourly, getMockForecastResponse(spot.SpotID)) {
+				windMph, _ := parseWindSpeed(resp.WindSpeed)
+				if resp.WindGustMph < windMph {
+					t.Errorf("gust %v mph is below the sustained %q", resp.WindGustMph, resp.WindSpeed)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMockData(t *testing.T) {
+	if err := validateMockData(); err != nil {
+		t.Fatalf("validateMockData() = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(*ForecastResponse)
+	}{
+		{"wave height", func(r *ForecastResponse) { r.WaveHeight = "head high" }},
+		{"wind speed", func(r *ForecastResponse) { r.WindSpeed = "breezy" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &mockForecast, func(spotID string) ForecastResponse {
+				response := getMockForecastResponse(spotID)
+				if spotID == huntington {
+					tt.corrupt(&response)
+				}
+				return response
+			})
+			err := validateMockData()
+			if err == nil || !strings.Contains(err.Error(), huntington) {
+				t.Errorf("validateMockData() = %v, want an error naming %s", err, huntington)
+			}
+		})
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	useProvider(t, newFakeProvider())
+	setForTest(t, &rateLimitPerMin, 0)
+	handler := newHandler()
+
+	paths := []string{
+		"/forecast?spotId=" + malibu,
+		"/forecast?spotId=" + malibu + "," + unknownSpotID,
+		"/forecast/best?spots=" + malibu,
+		"/spots",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			var bodies []string
+			for _, prefix := range []string{"", "/v" + API_VERSION} {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, prefix+path, nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s%s: status = %d, want %d", prefix, path, w.Code, http.StatusOK)
+				}
+				bodies = append(bodies, w.Body.String())
+			}
+			if bodies[0] != bodies[1] {
+				t.Errorf("bodies differ:\n%s\n%s", bodies[0], bodies[1])
+			}
+			if strings.HasPrefix(path, "/forecast") {
+				if got, want := strings.Count(bodies[0], `"apiVersion":"`+API_VERSION+`"`), strings.Count(bodies[0], `"spotId"`); got != want {
+					t.Errorf("%d of %d forecasts have an apiVersion: %s", got, want, bodies[0])
+				}
+			}
+		})
+	}
+}
+
+// cacheControl splits a Cache-Control header into its scope and max-age
+func cacheControl(t *testing.T, header string) (string, int64) {
+	t.Helper()
+	scope, maxAge, ok := strings.Cut(header, ", max-age=")
+	seconds, err := strconv.ParseInt(maxAge, 10, 64)
+	if !ok || err != nil {
+		t.Fatalf("malformed Cache-Control %q", header)
+	}
+	return scope, seconds
+}
+
+func TestSetCacheControl(t *testing.T) {
+	tests := []struct {
+		name           string
+		apiKeyRequired bool
+		bypassCache    bool
+		ttls           map[string]int64
+		spotIDs        []string
+		wantScope      string
+		wantMaxAge     int64
+	}{
+		{"remaining ttl", false, false, map[string]int64{"a": 120}, []string{"a"}, "public", 120},
+		{"soonest expiry wins", false, false, map[string]int64{"a": 120, "b": 30}, []string{"a", "b"}, "public", 30},
+		{"uncached spot", false, false, nil, []string{"a"}, "public", 0},
+		{"expired entry", false, false, map[string]int64{"a": -30}, []string{"a"}, "public", 0},
+		{"bypass", false, true, map[string]int64{"a": 120}, []string{"a"}, "public", 0},
+		{"private with api keys", true, false, map[string]int64{"a": 120}, []string{"a"}, "private", 120},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := useCache(t)
+			for spotID, ttl := range tt.ttls {
+				cache.Set(spotID, ForecastResponse{SpotID: spotID}, time.Now().Unix()+ttl)
+			}
+			setForTest(t, &apiKeyRequired, tt.apiKeyRequired)
+
+			w := httptest.NewRecorder()
+			setCacheControl(w, tt.spotIDs, tt.bypassCache)
+
+			// A second may tick over between caching and reading the header
+			scope, maxAge := cacheControl(t, w.Header().Get("Cache-Control"))
+			if scope != tt.wantScope || maxAge > tt.wantMaxAge || maxAge < tt.wantMaxAge-1 {
+				t.Errorf("Cache-Control = %s max-age %d, want %s max-age %d", scope, maxAge, tt.wantScope, tt.wantMaxAge)
+			}
+		})
+	}
+}
+
+func TestHandleForecastCacheControl(t *testing.T) {
+	useProvider(t, newFakeProvider())
+	setForTest(t, &cacheDuration, int64(600))
+
+	for _, query := range []string{"", "&bypassCache=true"} {
+		w := httptest.NewRecorder()
+		handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+
+		_, maxAge := cacheControl(t, w.Header().Get("Cache-Control"))
+		want := cacheTTL(malibu)
+		if query != "" {
+			want = 0
+		}
+		if maxAge > want || maxAge < want-1 {
+			t.Errorf("%q max-age = %d, want about %d", query, maxAge, want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	forecastRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "forecast_requests_total",
+		Help: "Forecasts served per spot, labelled by whether the cache was hit, missed or bypassed.",
+	}, []string{"spot", "cache"})
+
+	forecastRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "forecast_request_duration_seconds",
+		Help:    "Time taken to handle /forecast requests.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// metricValue scrapes /metrics and returns the value of the sample written
+// as series, e.g. forecast_requests_total{cache="hit",spot="..."}
+func metricValue(t *testing.T, handler http.Handler, series string) float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/metrics status = %d", w.Code)
+	}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), series+" "); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("parsing %s: %v", scanner.Text(), err)
+			}
+			return parsed
+		}
+	}
+	return 0
+}
+
+func TestForecastRequestsTotal(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		cache string
+	}{
+		{"miss", "", "miss"},
+		{"hit", "", "hit"},
+		{"bypass", "&bypassCache=true", "bypass"},
+	}
+	useProvider(t, newFakeProvider())
+	handler := newHandler()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := fmt.Sprintf("forecast_requests_total{cache=%q,spot=%q}", tt.cache, malibu)
+			before := metricValue(t, handler, series)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			if got := metricValue(t, handler, series) - before; got != 1 {
+				t.Errorf("%s went up by %v, want 1", series, got)
+			}
+		})
+	}
+}
+
+func TestForecastRequestDuration(t *testing.T) {
+	useProvider(t, newFakeProvider())
+	handler := newHandler()
+	before := metricValue(t, handler, "forecast_request_duration_seconds_count")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu, nil))
+
+	if got := metricValue(t, handler, "forecast_request_duration_seconds_count") - before; got != 1 {
+		t.Errorf("duration count went up by %v, want 1", got)
+	}
+}
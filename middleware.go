@@ -0,0 +1,281 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/rs/cors"
+)
+
+// corsMiddleware lets browser clients on allowedOrigins call the API and
+// answers preflight requests with 204 No Content
+func corsMiddleware(next http.Handler) http.Handler {
+	return cors.New(cors.Options{
+		AllowedOrigins:       allowedOrigins,
+		AllowedMethods:       []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowedHeaders:       []string{"Accept", "Authorization", "Content-Type", "X-Api-Key", "X-Request-ID"},
+		ExposedHeaders:       []string{"X-Request-ID", "X-Cache"},
+		OptionsSuccessStatus: http.StatusNoContent,
+	}).Handler(next)
+}
+
+type requestIDKey struct{}
+
+// Longest client-supplied request ID that is accepted as-is
+const MAX_REQUEST_ID_LENGTH = 128
+
+// requestIDMiddleware tags each request with the caller's X-Request-ID, or a
+// generated UUID when it is absent or unusable, and echoes it back in the
+// response
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = newUUID()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIDFromContext returns the ID assigned by requestIDMiddleware
+func requestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
+}
+
+// validRequestID accepts short IDs of printable ASCII, so client input can't
+// inject control characters into logs or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// recoverMiddleware turns a panicking handler into a 500 response instead of
+// a dropped connection, logging the panic with its stack trace
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// The server uses this sentinel to abort a response deliberately
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			slog.ErrorContext(r.Context(), "handler panicked", "event", "panic", "path", r.URL.Path, "error", err, "stack", string(debug.Stack()))
+			writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Internal server error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Default limits on request size, see MAX_BODY_BYTES and MAX_QUERY_LENGTH
+const (
+	DEFAULT_MAX_BODY_BYTES   = 64 << 10
+	DEFAULT_MAX_QUERY_LENGTH = 2048
+)
+
+// limitMiddleware rejects query strings longer than maxQueryLength with 414
+// and caps request bodies at maxBodyBytes. Bodies that declare an oversize
+// Content-Length are refused up front; others fail on read past the limit.
+func limitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxQueryLength > 0 && len(r.URL.RawQuery) > maxQueryLength {
+			writeJSONError(w, http.StatusRequestURITooLong, ERR_QUERY_TOO_LONG, "Query string too long")
+			return
+		}
+		if maxBodyBytes > 0 {
+			if r.ContentLength > maxBodyBytes {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, ERR_BODY_TOO_LARGE, "Request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Default duration after which a request is logged as slow, see
+// SLOW_REQUEST_MS
+const DEFAULT_SLOW_REQUEST_MS = 1000
+
+// timingMiddleware logs how long each request took, at debug level normally
+// and as a warning once it exceeds slowRequestThreshold
+func timingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		duration := time.Since(start)
+
+		level := slog.LevelDebug
+		if slowRequestThreshold > 0 && duration >= slowRequestThreshold {
+			level = slog.LevelWarn
+		}
+		slog.Log(r.Context(), level, "request completed", "event", "request", "method", r.Method, "path", r.URL.Path, "status", sw.status, "durationMs", duration.Milliseconds())
+	})
+}
+
+// statusWriter remembers the status code a handler responded with
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(p)
+}
+
+// Responses smaller than this are sent uncompressed, since gzip overhead
+// outweighs the savings
+const GZIP_MIN_SIZE = 1024
+
+// gzipMiddleware compresses responses for clients that accept gzip
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		// HEAD has no body to compress, so its Content-Length stays accurate
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(encoding, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0"
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response and only compresses it
+// once it reaches GZIP_MIN_SIZE. Responses that already carry a
+// Content-Encoding are passed through untouched.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	buf         []byte
+	status      int
+	wroteHeader bool
+	passthrough bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.wroteHeader || g.gz != nil || g.passthrough {
+		return
+	}
+	g.status = status
+	g.wroteHeader = true
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	if g.passthrough {
+		return g.ResponseWriter.Write(p)
+	}
+
+	g.buf = append(g.buf, p...)
+	if len(g.buf) >= GZIP_MIN_SIZE {
+		if err := g.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start commits the headers and writes the buffered bytes, compressed unless
+// the handler already encoded the body itself
+func (g *gzipResponseWriter) start() error {
+	header := g.ResponseWriter.Header()
+	if header.Get("Content-Type") == "" {
+		// Sniff from the uncompressed bytes, not the gzip stream
+		header.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+
+	buf := g.buf
+	g.buf = nil
+
+	if header.Get("Content-Encoding") != "" {
+		g.passthrough = true
+		g.ResponseWriter.WriteHeader(g.status)
+		_, err := g.ResponseWriter.Write(buf)
+		return err
+	}
+
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	g.ResponseWriter.WriteHeader(g.status)
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	_, err := g.gz.Write(buf)
+	return err
+}
+
+// Close flushes any buffered response, uncompressed if it stayed small
+func (g *gzipResponseWriter) Close() error {
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	if g.passthrough {
+		return nil
+	}
+	if g.wroteHeader {
+		g.ResponseWriter.WriteHeader(g.status)
+	}
+	if len(g.buf) > 0 {
+		_, err := g.ResponseWriter.Write(g.buf)
+		return err
+	}
+	return nil
+}
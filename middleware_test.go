@@ -0,0 +1,352 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGzipMiddleware(t *testing.T) {
+	large := `{"padding":"` + strings.Repeat("a", GZIP_MIN_SIZE) + `"}`
+	small := `{"status":"ok"}`
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		wantGzip       bool
+	}{
+		{"large body", "gzip, deflate", large, true},
+		{"below GZIP_MIN_SIZE", "gzip", small, false},
+		{"gzip not accepted", "", large, false},
+		{"gzip refused", "gzip;q=0, identity", large, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, tt.body)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/forecast", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+				t.Fatalf("compressed = %v, want %v", got, tt.wantGzip)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			body := w.Body.String()
+			if tt.wantGzip {
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader: %v", err)
+				}
+				decompressed, err := io.ReadAll(reader)
+				if err != nil {
+					t.Fatalf("decompressing: %v", err)
+				}
+				body = string(decompressed)
+			}
+			if body != tt.body {
+				t.Errorf("body = %.40q..., want %.40q...", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestGzipMiddlewareSkipsHead(t *testing.T) {
+	large := `{"padding":"` + strings.Repeat("a", GZIP_MIN_SIZE) + `"}`
+	handler := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeBody(w, r, "application/json", []byte(large))
+	}))
+	r := httptest.NewRequest(http.MethodHead, "/forecast", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if got, want := w.Header().Get("Content-Length"), strconv.Itoa(len(large)); got != want {
+		t.Errorf("Content-Length = %q, want %q", got, want)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD returned a %d byte body", w.Body.Len())
+	}
+}
+
+func TestGzipForecastRange(t *testing.T) {
+	useCache(t)
+	handler := gzipMiddleware(http.HandlerFunc(handleForecast))
+
+	r := httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+"&from=2024-06-01T00:00:00Z&to=2024-06-01T06:00:00Z", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	var responses []ForecastResponse
+	if err := json.NewDecoder(reader).Decode(&responses); err != nil {
+		t.Fatalf("decoding decompressed body: %v", err)
+	}
+	if len(responses) != 6 {
+		t.Errorf("got %d hours, want 6", len(responses))
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"get", []string{"*"}, "https://example.com", http.MethodGet, "", true},
+		{"content type", []string{"*"}, "https://example.com", http.MethodGet, "Content-Type", true},
+		{"admin cache eviction", []string{"*"}, "https://example.com", http.MethodDelete, "Authorization", true},
+		{"request id", []string{"*"}, "https://example.com", http.MethodGet, "X-Request-ID", true},
+		{"spot registration", []string{"*"}, "https://example.com", http.MethodPost, "Authorization, Content-Type", true},
+		{"saving favorites", []string{"*"}, "https://example.com", http.MethodPut, "Content-Type", true},
+		{"monitor probe", []string{"*"}, "https://example.com", http.MethodHead, "", true},
+		{"api key", []string{"*"}, "https://example.com", http.MethodGet, "X-Api-Key", true},
+		{"configured origin", []string{"https://surf.example"}, "https://surf.example", http.MethodGet, "", true},
+		{"other origin", []string{"https://surf.example"}, "https://example.com", http.MethodGet, "", false},
+		{"patch", []string{"*"}, "https://example.com", http.MethodPatch, "", false},
+		{"unlisted header", []string{"*"}, "https://example.com", http.MethodGet, "X-Custom", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &allowedOrigins, tt.origins)
+			handler := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("preflight reached the handler")
+			}))
+
+			r := httptest.NewRequest(http.MethodOptions, "/forecast", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+			got := w.Header().Get("Access-Control-Allow-Origin") != ""
+			if got != tt.allowed {
+				t.Errorf("allowed = %v, want %v", got, tt.allowed)
+			}
+			if tt.allowed && !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), tt.method) {
+				t.Errorf("Access-Control-Allow-Methods = %q, want it to include %s", w.Header().Get("Access-Control-Allow-Methods"), tt.method)
+			}
+		})
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantEcho bool
+	}{
+		{"provided", "req-123", true},
+		{"missing", "", false},
+		{"control characters", "req\x01123", false},
+		{"too long", strings.Repeat("a", MAX_REQUEST_ID_LENGTH+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen, _ = requestIDFromContext(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/forecast", nil)
+			if tt.incoming != "" {
+				r.Header.Set("X-Request-ID", tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			got := w.Header().Get("X-Request-ID")
+			if got != seen {
+				t.Errorf("response ID %q differs from the context's %q", got, seen)
+			}
+			if tt.wantEcho && got != tt.incoming {
+				t.Errorf("X-Request-ID = %q, want the provided %q", got, tt.incoming)
+			}
+			if !tt.wantEcho && !uuidPattern.MatchString(got) {
+				t.Errorf("X-Request-ID = %q, want a generated UUID", got)
+			}
+		})
+	}
+}
+
+// A panicking handler gets the client a JSON 500, and the server goes on
+// serving later requests
+func TestRecoverMiddleware(t *testing.T) {
+	logs := recordLogs(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	server := httptest.NewServer(recoverMiddleware(mux))
+	t.Cleanup(server.Close)
+
+	resp, err := server.Client().Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatalf("GET /panic: %v", err)
+	}
+	var body map[string]string
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || err != nil || body["error"] == "" {
+		t.Errorf("GET /panic = %d, %v (decode error %v), want a JSON 500", resp.StatusCode, body, err)
+	}
+	attrs, ok := logs.find("panic")
+	if !ok {
+		t.Fatal("the panic was not logged")
+	}
+	if attrs["error"] != "boom" || !strings.Contains(attrs["stack"], "goroutine") {
+		t.Errorf("panic record = %v, want the value and a stack trace", attrs)
+	}
+
+	resp, err = server.Client().Get(server.URL + "/ok")
+	if err != nil {
+		t.Fatalf("GET /ok after a panic: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /ok after a panic = %d, want 200", resp.StatusCode)
+	}
+}
+
+// http.ErrAbortHandler is left for net/http to handle
+func TestRecoverMiddlewareAbort(t *testing.T) {
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("ErrAbortHandler was swallowed")
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		sleep     time.Duration
+		status    int
+		wantLevel slog.Level
+	}{
+		{"fast", 0, http.StatusOK, slog.LevelDebug},
+		{"slow", 60 * time.Millisecond, http.StatusTeapot, slog.LevelWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &slowRequestThreshold, 50*time.Millisecond)
+			logs := recordLogs(t)
+			handler := timingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.sleep)
+				w.WriteHeader(tt.status)
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu, nil))
+
+			logs.mu.Lock()
+			records := append([]slog.Record{}, *logs.records...)
+			logs.mu.Unlock()
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			if records[0].Level != tt.wantLevel {
+				t.Errorf("level = %v, want %v", records[0].Level, tt.wantLevel)
+			}
+			attrs, _ := logs.find("request")
+			if attrs["method"] != http.MethodGet || attrs["path"] != "/forecast" || attrs["status"] != fmt.Sprint(tt.status) || attrs["durationMs"] == "" {
+				t.Errorf("request record = %v", attrs)
+			}
+		})
+	}
+}
+
+// Oversize requests are refused through the full handler chain with a JSON
+// error, whether the body declares its length or not
+func TestLimitMiddleware(t *testing.T) {
+	setForTest(t, &maxBodyBytes, 64)
+	setForTest(t, &maxQueryLength, 100)
+	setForTest(t, &rateLimitPerMin, 0)
+	useFavorites(t, "")
+	handler := newHandler()
+
+	oversize := `{"spotIds":["` + strings.Repeat("a", 100) + `"]}`
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    io.Reader
+		chunked bool
+		want    int
+		wantErr string
+	}{
+		{"declared oversize body", http.MethodPut, "/favorites?user=kai", strings.NewReader(oversize), false, http.StatusRequestEntityTooLarge, "Request body too large"},
+		{"chunked oversize body", http.MethodPut, "/favorites?user=kai", strings.NewReader(oversize), true, http.StatusRequestEntityTooLarge, "Request body too large"},
+		{"body within the limit", http.MethodPut, "/favorites?user=kai", strings.NewReader(`{"spotIds":[]}`), false, http.StatusOK, ""},
+		{"long query", http.MethodGet, "/forecast?spotId=" + strings.Repeat("a", 100), nil, false, http.StatusRequestURITooLong, "Query string too long"},
+		{"query within the limit", http.MethodGet, "/forecast?spotId=" + malibu, nil, false, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCache(t)
+			r := httptest.NewRequest(tt.method, tt.target, tt.body)
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantErr == "" {
+				return
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if body["error"] != tt.wantErr {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const EARTH_RADIUS_KM = 6371.0
+
+// haversineKm returns the great-circle distance between two points in km
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EARTH_RADIUS_KM * math.Asin(math.Sqrt(a))
+}
+
+// nearestSpot returns the ID of the spot closest to the given point, breaking
+// ties by spot ID so the result is deterministic
+func nearestSpot(lat, lon float64) (string, bool) {
+	bestID := ""
+	bestKm := math.Inf(1)
+	for _, spot := range knownSpots.List() {
+		km := haversineKm(lat, lon, spot.Lat, spot.Lon)
+		if km < bestKm || (km == bestKm && spot.SpotID < bestID) {
+			bestID = spot.SpotID
+			bestKm = km
+		}
+	}
+	return bestID, bestID != ""
+}
+
+// handleNearest serves the forecast for the spot closest to lat/lon, accepting
+// the same options as /forecast
+func handleNearest(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Missing or invalid lat parameter")
+		return
+	}
+	lon, err := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Missing or invalid lon parameter")
+		return
+	}
+
+	spotID, ok := nearestSpot(lat, lon)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "No spots available")
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del("lat")
+	query.Del("lon")
+	query.Set("spotId", spotID)
+	forecastReq := r.Clone(r.Context())
+	forecastReq.URL.RawQuery = query.Encode()
+	handleForecast(w, forecastReq)
+}
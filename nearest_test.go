@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHaversineKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 34, -118, 34, -118, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111.19},
+		{"los angeles to san francisco", 34.0522, -118.2437, 37.7749, -122.4194, 559.12},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111.19},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := haversineKm(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("haversineKm() = %.2f, want %.2f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNearestSpot(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{"at malibu", 34.0360, -118.6780, malibu},
+		{"santa monica", 34.0195, -118.4912, malibu},
+		{"newport beach", 33.6189, -117.9289, huntington},
+		{"nicaragua", 11.25, -85.87, tamarindo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := nearestSpot(tt.lat, tt.lon); !ok || got != tt.want {
+				t.Errorf("nearestSpot() = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleNearest(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		want     int
+		wantSpot string
+	}{
+		{"santa monica", "?lat=34.0195&lon=-118.4912", http.StatusOK, malibu},
+		{"missing lat", "?lon=-118.4912", http.StatusBadRequest, ""},
+		{"lat out of range", "?lat=91&lon=0", http.StatusBadRequest, ""},
+		{"lon out of range", "?lat=0&lon=-181", http.StatusBadRequest, ""},
+		{"lon not a number", "?lat=0&lon=west", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, newFakeProvider())
+
+			w := httptest.NewRecorder()
+			handleNearest(w, httptest.NewRequest(http.MethodGet, "/forecast/nearest"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var response ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if response.SpotID != tt.wantSpot {
+				t.Errorf("SpotID = %s, want %s", response.SpotID, tt.wantSpot)
+			}
+		})
+	}
+}
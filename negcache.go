@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Default lifetime of a cached not-found result, see NEGATIVE_CACHE_SECONDS
+const DEFAULT_NEGATIVE_CACHE_SECONDS = 60
+
+// Most not-found spot IDs remembered at once, so a client cycling through
+// random IDs can't grow the cache without bound
+const MAX_NEGATIVE_CACHE_ENTRIES = 10000
+
+// negativeCache remembers spot IDs that were recently looked up and not
+// found, each expiring on its own schedule
+type negativeCache struct {
+	mu      sync.Mutex
+	entries map[string]int64
+}
+
+func newNegativeCache() *negativeCache {
+	return &negativeCache{entries: make(map[string]int64)}
+}
+
+// Has reports whether spotID is cached as not found
+func (c *negativeCache) Has(spotID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt, ok := c.entries[spotID]
+	if !ok {
+		return false
+	}
+	if expiresAt <= appClock.Now().Unix() {
+		delete(c.entries, spotID)
+		return false
+	}
+	return true
+}
+
+// Add caches spotID as not found for negativeCacheTTL seconds. When the
+// cache is full of live entries the ID is simply not remembered.
+func (c *negativeCache) Add(spotID string) {
+	if negativeCacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := appClock.Now().Unix()
+	if len(c.entries) >= MAX_NEGATIVE_CACHE_ENTRIES {
+		for id, expiresAt := range c.entries {
+			if expiresAt <= now {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= MAX_NEGATIVE_CACHE_ENTRIES {
+			return
+		}
+	}
+	c.entries[spotID] = now + negativeCacheTTL
+}
+
+// Delete forgets spotID, e.g. once it has been registered
+func (c *negativeCache) Delete(spotID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, spotID)
+}
+
+// Spot IDs recently found not to be registered
+var unknownSpots = newNegativeCache()
+
+// lookupSpot is how request handlers resolve a spot ID, so every endpoint
+// answers unknown IDs from the same negative cache. While the registry is
+// held in memory a negative hit saves little over the lookup itself; the
+// cache is there so repeated probes for bogus IDs stay cheap should spots
+// move to a slower backing store.
+func lookupSpot(ctx context.Context, spotID string) (Spot, bool) {
+	if unknownSpots.Has(spotID) {
+		slog.DebugContext(ctx, "unknown spot served from negative cache", "event", "negative_cache_hit", "spotId", spotID)
+		return Spot{}, false
+	}
+	spot, ok := knownSpots.Get(spotID)
+	if !ok {
+		unknownSpots.Add(spotID)
+	}
+	return spot, ok
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNegativeCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     int64
+		advance time.Duration
+		want    bool
+	}{
+		{"remembered", 60, 59 * time.Second, true},
+		{"expired", 60, 60 * time.Second, false},
+		{"disabled", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := useFakeClock(t, time.Unix(1_700_000_000, 0))
+			previous := negativeCacheTTL
+			negativeCacheTTL = tt.ttl
+			t.Cleanup(func() { negativeCacheTTL = previous })
+
+			cache := newNegativeCache()
+			cache.Add("bogus")
+			clock.Advance(tt.advance)
+			if got := cache.Has("bogus"); got != tt.want {
+				t.Errorf("Has() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// useNegativeCache gives the test an empty negative cache of its own
+func useNegativeCache(t *testing.T) *negativeCache {
+	t.Helper()
+	previous := unknownSpots
+	unknownSpots = newNegativeCache()
+	t.Cleanup(func() { unknownSpots = previous })
+	return unknownSpots
+}
+
+func TestLookupSpot(t *testing.T) {
+	tests := []struct {
+		name         string
+		spotID       string
+		want         bool
+		wantNegative bool
+	}{
+		{"registered", "5842041f4e65fad6a7708814", true, false},
+		{"unregistered", "000000000000000000000000", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negative := useNegativeCache(t)
+			if _, ok := lookupSpot(context.Background(), tt.spotID); ok != tt.want {
+				t.Errorf("lookupSpot() ok = %v, want %v", ok, tt.want)
+			}
+			if got := negative.Has(tt.spotID); got != tt.wantNegative {
+				t.Errorf("cached as unknown = %v, want %v", got, tt.wantNegative)
+			}
+		})
+	}
+}
+
+func TestUnknownSpotServedFromNegativeCache(t *testing.T) {
+	const spotID = "000000000000000000000000"
+	handlers := []struct {
+		name    string
+		handler http.HandlerFunc
+		url     string
+	}{
+		{"forecast", handleForecast, "/forecast?spotId=" + spotID},
+		{"summary", handleSummary, "/forecast/summary?spotId=" + spotID},
+		{"history", handleHistory, "/forecast/history?spotId=" + spotID},
+		{"spot details", handleSpotDetails, "/spots/" + spotID},
+	}
+	for _, tt := range handlers {
+		t.Run(tt.name, func(t *testing.T) {
+			negative := useNegativeCache(t)
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				tt.handler(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+				if w.Code != http.StatusNotFound {
+					t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusNotFound)
+				}
+				if !negative.Has(spotID) {
+					t.Fatalf("request %d: spot not cached as unknown", i+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ForecastProvider fetches a fresh forecast for a single spot.
+type ForecastProvider interface {
+	Fetch(ctx context.Context, spotID string) (ForecastResponse, error)
+}
+
+// ForecastRangeProvider is implemented by providers that can forecast the
+// hours ahead rather than only the current conditions.
+type ForecastRangeProvider interface {
+	// FetchRange returns one forecast per hour in [from, to)
+	FetchRange(ctx context.Context, spotID string, from, to time.Time) ([]ForecastResponse, error)
+}
+
+// Origins of a served forecast
+const (
+	SOURCE_LIVE = "live" // fetched from the real forecast source
+	SOURCE_MOCK = "mock" // canned data from mockProvider
+)
+
+// Default time allowed for a provider fetch including its retries, see
+// FETCH_TIMEOUT_MS
+const DEFAULT_FETCH_TIMEOUT_MS = 5000
+
+// forecastProvider is the provider used by the handlers, selected at startup
+var forecastProvider ForecastProvider = mockProvider{}
+
+// newForecastProvider returns the provider for a FORECAST_SOURCE value.
+// An empty source selects the mock provider.
+func newForecastProvider(source string) (ForecastProvider, error) {
+	switch source {
+	case "", "mock":
+		return mockProvider{}, nil
+	case "surfline":
+		return newSurflineProvider(newUpstreamClient()), nil
+	default:
+		return nil, fmt.Errorf("unknown forecast source %q", source)
+	}
+}
+
+// mockProvider serves canned forecasts without any network access
+type mockProvider struct{}
+
+func (mockProvider) Fetch(ctx context.Context, spotID string) (ForecastResponse, error) {
+	return mockForecast(spotID), nil
+}
+
+// FetchRange synthesizes hourly forecasts by varying the canned values over
+// a 12-hour cycle, so consecutive hours differ slightly.
+func (mockProvider) FetchRange(ctx context.Context, spotID string, from, to time.Time) ([]ForecastResponse, error) {
+	base := mockForecast(spotID)
+
+	baseWindMph, _ := parseWindSpeed(base.WindSpeed)
+
+	var responses []ForecastResponse
+	start := from.Truncate(time.Hour)
+	if start.Before(from) {
+		start = start.Add(time.Hour)
+	}
+	spot, known := knownSpots.Get(spotID)
+	for t := start; t.Before(to); t = t.Add(time.Hour) {
+		response := base
+		response.Timestamp = t.Unix()
+		if known {
+			response.UVIndex = mockUVIndex(spot.Lat, spot.Lon, t)
+		}
+
+		// Unknown spots have nothing to vary
+		if base.WaveHeightFt > 0 {
+			phase := 2 * math.Pi * float64(t.Hour()) / 12
+			waveFactor := 1 + 0.15*math.Sin(phase)
+			response.WaveHeightFt = math.Round(base.WaveHeightFt*waveFactor*10) / 10
+			response.WaveHeightMinFt = math.Round(base.WaveHeightMinFt*waveFactor*10) / 10
+			response.WaveHeightMaxFt = math.Round(base.WaveHeightMaxFt*waveFactor*10) / 10
+			response.SwellPeriodSec = base.SwellPeriodSec + int(math.Round(math.Cos(phase)))
+			response.WaveHeight = formatWaveHeight(response.WaveHeightMinFt, response.WaveHeightMaxFt, response.SwellPeriodSec, response.SwellDirectionDeg)
+			windFactor := 1 + 0.3*math.Sin(phase+math.Pi/2)
+			response.WindSpeed = fmt.Sprintf("%.0f mph", baseWindMph*windFactor)
+			response.WindGustMph = math.Round(base.WindGustMph * windFactor)
+
+			response.Swells = append([]Swell{}, base.Swells...)
+			response.Swells[0].HeightFt = response.WaveHeightFt
+			response.Swells[0].PeriodSec = response.SwellPeriodSec
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// ErrUpstreamDecode is returned when the forecast source answers with a body
+// that can't be decoded. Nothing from such a response is used.
+var ErrUpstreamDecode = errors.New("could not decode upstream response")
+
+// Default Surfline forecast API, see SURFLINE_BASE_URL
+const surflineBaseURL = "https://services.surfline.com/kbyg/spots/forecasts"
+
+// Credit shown on live forecasts, see ATTRIBUTION_TEXT
+const DEFAULT_ATTRIBUTION_TEXT = "Forecast data provided by Surfline"
+
+// surflineProvider fetches forecasts from the public Surfline KBYG API
+type surflineProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newSurflineProvider(client *http.Client) *surflineProvider {
+	return &surflineProvider{
+		baseURL: strings.TrimSuffix(surflineURL, "/"),
+		client:  client,
+	}
+}
+
+// Upstream connection pool defaults. Every request goes to the same host, so
+// it gets most of the idle pool.
+const (
+	DEFAULT_UPSTREAM_TIMEOUT_SECONDS           = 10
+	DEFAULT_UPSTREAM_IDLE_CONN_TIMEOUT_SECONDS = 90
+	UPSTREAM_MAX_IDLE_CONNS                    = 100
+	UPSTREAM_MAX_IDLE_CONNS_PER_HOST           = 32
+	UPSTREAM_MAX_CONNS_PER_HOST                = 64
+	UPSTREAM_TLS_HANDSHAKE_TIMEOUT             = 5 * time.Second
+)
+
+// newUpstreamClient builds the HTTP client shared by every request to the
+// forecast source, pooling connections so they are kept alive between fetches
+func newUpstreamClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = UPSTREAM_MAX_IDLE_CONNS
+	transport.MaxIdleConnsPerHost = UPSTREAM_MAX_IDLE_CONNS_PER_HOST
+	transport.MaxConnsPerHost = UPSTREAM_MAX_CONNS_PER_HOST
+	transport.IdleConnTimeout = upstreamIdleConnTimeout
+	transport.TLSHandshakeTimeout = UPSTREAM_TLS_HANDSHAKE_TIMEOUT
+	return &http.Client{Timeout: upstreamTimeout, Transport: transport}
+}
+
+type surflineWaveResponse struct {
+	Data struct {
+		Wave []struct {
+			Timestamp int64 `json:"timestamp"`
+			Swells    []struct {
+				Height    float64 `json:"height"`
+				Period    int     `json:"period"`
+				Direction float64 `json:"direction"`
+			} `json:"swells"`
+		} `json:"wave"`
+	} `json:"data"`
+}
+
+type surflineWindResponse struct {
+	Data struct {
+		Wind []struct {
+			Timestamp     int64   `json:"timestamp"`
+			Speed         float64 `json:"speed"`
+			Gust          float64 `json:"gust"`
+			Direction     float64 `json:"direction"`
+			DirectionType string  `json:"directionType"`
+		} `json:"wind"`
+	} `json:"data"`
+}
+
+type surflineTidesResponse struct {
+	Data struct {
+		Tides []struct {
+			Timestamp int64   `json:"timestamp"`
+			Type      string  `json:"type"`
+			Height    float64 `json:"height"`
+		} `json:"tides"`
+	} `json:"data"`
+}
+
+func (p *surflineProvider) Fetch(ctx context.Context, spotID string) (ForecastResponse, error) {
+	var wave surflineWaveResponse
+	if err := p.get(ctx, "wave", spotID, &wave); err != nil {
+		return ForecastResponse{}, err
+	}
+	var wind surflineWindResponse
+	if err := p.get(ctx, "wind", spotID, &wind); err != nil {
+		return ForecastResponse{}, err
+	}
+	var tides surflineTidesResponse
+	if err := p.get(ctx, "tides", spotID, &tides); err != nil {
+		return ForecastResponse{}, err
+	}
+
+	if len(wave.Data.Wave) == 0 || len(wave.Data.Wave[0].Swells) == 0 || len(wind.Data.Wind) == 0 {
+		return ForecastResponse{}, fmt.Errorf("surfline returned no forecast data for spot %s", spotID)
+	}
+
+	location, ok := spotLocation(spotID)
+	if !ok {
+		location = "Unknown Location"
+	}
+
+	// Surfline always returns a fixed number of swell slots, with empty ones
+	// zeroed; keep the real ones, largest first
+	var swells []Swell
+	for _, swell := range wave.Data.Wave[0].Swells {
+		if swell.Height > 0 {
+			swells = append(swells, Swell{HeightFt: swell.Height, PeriodSec: swell.Period, DirectionDeg: int(swell.Direction)})
+		}
+	}
+	if len(swells) == 0 {
+		return ForecastResponse{}, fmt.Errorf("surfline returned no swells for spot %s", spotID)
+	}
+	sort.SliceStable(swells, func(i, j int) bool {
+		return swells[i].HeightFt > swells[j].HeightFt
+	})
+	primary := swells[0]
+	currentWind := wind.Data.Wind[0]
+
+	response := ForecastResponse{
+		SpotID:        spotID,
+		Location:      location,
+		WaveHeight:    formatWaveHeight(primary.HeightFt, primary.HeightFt, primary.PeriodSec, primary.DirectionDeg),
+		WindSpeed:     fmt.Sprintf("%.0f mph", currentWind.Speed),
+		WindDirection: currentWind.DirectionType,
+		Tide:          describeTide(tides),
+		Timestamp:     appClock.Now().Unix(),
+		Units:         UNITS_IMPERIAL,
+		Source:        SOURCE_LIVE,
+		Attribution:   attributionText,
+
+		WaveHeightFt:      primary.HeightFt,
+		WaveHeightMinFt:   primary.HeightFt,
+		WaveHeightMaxFt:   primary.HeightFt,
+		SwellPeriodSec:    primary.PeriodSec,
+		SwellDirectionDeg: primary.DirectionDeg,
+		Swells:            swells,
+		TideEvents:        tideEvents(tides),
+		WindDegrees:       int(currentWind.Direction),
+		WindGustMph:       currentWind.Gust,
+	}
+	return response, nil
+}
+
+// get fetches one Surfline forecast resource and decodes it into out
+func (p *surflineProvider) get(ctx context.Context, resource, spotID string, out interface{}) error {
+	query := url.Values{}
+	query.Set("spotId", spotID)
+	query.Set("days", "1")
+	query.Set("units[swellHeight]", "FT")
+	query.Set("units[waveHeight]", "FT")
+	query.Set("units[windSpeed]", "MPH")
+	query.Set("units[tideHeight]", "FT")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/"+resource+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("surfline %s request failed: %w", resource, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &upstreamStatusError{Resource: resource, StatusCode: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			statusErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), appClock.Now())
+		}
+		return statusErr
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: surfline %s: %v", ErrUpstreamDecode, resource, err)
+	}
+	return nil
+}
+
+// tideEvents returns the next TIDE_EVENT_COUNT high and low tides
+func tideEvents(tides surflineTidesResponse) []TideEvent {
+	now := appClock.Now().Unix()
+	var events []TideEvent
+	for _, tide := range tides.Data.Tides {
+		if tide.Timestamp < now || (tide.Type != "HIGH" && tide.Type != "LOW") {
+			continue
+		}
+		events = append(events, TideEvent{
+			Type:     strings.ToLower(tide.Type),
+			HeightFt: tide.Height,
+			Time:     tide.Timestamp,
+		})
+		if len(events) == TIDE_EVENT_COUNT {
+			break
+		}
+	}
+	return events
+}
+
+// describeTide summarizes the next tide turn, e.g. "Rising, 2.5ft at 10:30am"
+func describeTide(tides surflineTidesResponse) string {
+	now := appClock.Now().Unix()
+	for _, tide := range tides.Data.Tides {
+		if tide.Timestamp < now || (tide.Type != "HIGH" && tide.Type != "LOW") {
+			continue
+		}
+		state := "Rising"
+		if tide.Type == "LOW" {
+			state = "Falling"
+		}
+		at := time.Unix(tide.Timestamp, 0).UTC().Format("3:04pm")
+		return fmt.Sprintf("%s, %.1fft at %s", state, tide.Height, at)
+	}
+	return "Unknown"
+}
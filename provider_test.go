@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeProvider counts the fetches made for each spot. Fetches block while
+// hold is open, and fetch, when set, decides what each one returns.
+type fakeProvider struct {
+	mu    sync.Mutex
+	calls map[string]int
+	hold  chan struct{}
+	fetch func(ctx context.Context, spotID string) (ForecastResponse, error)
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{calls: make(map[string]int)}
+}
+
+func (p *fakeProvider) Fetch(ctx context.Context, spotID string) (ForecastResponse, error) {
+	p.mu.Lock()
+	p.calls[spotID]++
+	hold, fetch := p.hold, p.fetch
+	p.mu.Unlock()
+
+	if hold != nil {
+		select {
+		case <-hold:
+		case <-ctx.Done():
+			return ForecastResponse{}, ctx.Err()
+		}
+	}
+	if fetch != nil {
+		return fetch(ctx, spotID)
+	}
+	return fakeForecast(spotID, 3.5), nil
+}
+
+// Calls returns how many fetches were made for spotID
+func (p *fakeProvider) Calls(spotID string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[spotID]
+}
+
+// fakeForecast is a plausible live forecast for spotID with waves of heightFt
+func fakeForecast(spotID string, heightFt float64) ForecastResponse {
+	location, _ := spotLocation(spotID)
+	return ForecastResponse{
+		SpotID:            spotID,
+		Location:          location,
+		WaveHeight:        formatWaveHeight(heightFt, heightFt, 12, 210),
+		WindSpeed:         "5 mph",
+		WindDirection:     "Offshore",
+		Tide:              "Rising, 2.5ft at 10:30am",
+		Timestamp:         time.Now().Unix(),
+		WaveHeightFt:      heightFt,
+		WaveHeightMinFt:   heightFt,
+		WaveHeightMaxFt:   heightFt,
+		SwellPeriodSec:    12,
+		SwellDirectionDeg: 210,
+		Units:             UNITS_IMPERIAL,
+		Source:            SOURCE_LIVE,
+	}
+}
+
+// useProvider serves forecasts from provider, starting from an empty cache
+func useProvider(t *testing.T, provider ForecastProvider) {
+	t.Helper()
+	setForTest(t, &forecastProvider, provider)
+	setForTest(t, &providerBreaker, newCircuitBreaker(DEFAULT_CIRCUIT_BREAKER_THRESHOLD, DEFAULT_CIRCUIT_BREAKER_COOLDOWN_SECONDS*time.Second))
+	useCache(t)
+}
+
+func TestNewForecastProvider(t *testing.T) {
+	tests := []struct {
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{"", "main.mockProvider", false},
+		{"mock", "main.mockProvider", false},
+		{"surfline", "*main.surflineProvider", false},
+		{"stormglass", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			provider, err := newForecastProvider(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newForecastProvider(%q) error = %v, wantErr %v", tt.source, err, tt.wantErr)
+			}
+			if got := fmt.Sprintf("%T", provider); !tt.wantErr && got != tt.want {
+				t.Errorf("newForecastProvider(%q) = %s, want %s", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockProviderFetchRange(t *testing.T) {
+	from := time.Date(2024, 6, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		from, to  time.Time
+		wantHours int
+		wantFirst time.Time
+	}{
+		{"starts at the next whole hour", from, from.Add(3 * time.Hour), 3, time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC)},
+		{"whole hour start is included", from.Truncate(time.Hour), from.Truncate(time.Hour).Add(2 * time.Hour), 2, time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{"empty window", from, from.Add(10 * time.Minute), 0, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses, err := mockProvider{}.FetchRange(context.Background(), malibu, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("FetchRange() error = %v", err)
+			}
+			if len(responses) != tt.wantHours {
+				t.Fatalf("got %d hours, want %d", len(responses), tt.wantHours)
+			}
+			if tt.wantHours > 0 && responses[0].Timestamp != tt.wantFirst.Unix() {
+				t.Errorf("first Timestamp = %v, want %v", time.Unix(responses[0].Timestamp, 0).UTC(), tt.wantFirst)
+			}
+		})
+	}
+}
+
+// surflineStub answers the wave, wind and tides resources the way Surfline
+// does, or with status for any resource listed in failures
+func surflineStub(t *testing.T, now time.Time, failures map[string]int) *httptest.Server {
+	t.Helper()
+	bodies := map[string]string{
+		"wave":  `{"data":{"wave":[{"timestamp":1,"swells":[{"height":1.5,"period":8,"direction":270},{"height":4.2,"period":14,"direction":205}]}]}}`,
+		"wind":  `{"data":{"wind":[{"timestamp":1,"speed":6.4,"gust":11,"direction":45,"directionType":"Offshore"}]}}`,
+		"tides": fmt.Sprintf(`{"data":{"tides":[{"timestamp":%d,"type":"HIGH","height":5},{"timestamp":%d,"type":"NORMAL","height":4},{"timestamp":%d,"type":"LOW","height":1.2}]}}`, now.Unix()-3600, now.Unix()+600, now.Unix()+7200),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resource := strings.TrimPrefix(r.URL.Path, "/")
+		if r.URL.Query().Get("spotId") == "" {
+			t.Errorf("%s request without spotId", resource)
+		}
+		if status, ok := failures[resource]; ok {
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "7")
+			}
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, bodies[resource])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// stubbedSurflineProvider fetches from server instead of Surfline
+func stubbedSurflineProvider(server *httptest.Server) *surflineProvider {
+	provider := newSurflineProvider(server.Client())
+	provider.baseURL = server.URL
+	return provider
+}
+
+func TestSurflineProviderFetch(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	useFakeClock(t, now)
+	setForTest(t, &attributionText, "Data from Surfline")
+	server := surflineStub(t, now, nil)
+
+	got, err := stubbedSurflineProvider(server).Fetch(context.Background(), malibu)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	tests := []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"Location", got.Location, "Malibu, CA"},
+		{"WaveHeight", got.WaveHeight, "4.2 ft at 14 seconds 205 degrees"},
+		{"WaveHeightFt", got.WaveHeightFt, 4.2},
+		{"WaveHeightMinFt", got.WaveHeightMinFt, 4.2},
+		{"WaveHeightMaxFt", got.WaveHeightMaxFt, 4.2},
+		{"SwellPeriodSec", got.SwellPeriodSec, 14},
+		{"Swells", len(got.Swells), 2},
+		{"secondary swell", got.Swells[1], Swell{HeightFt: 1.5, PeriodSec: 8, DirectionDeg: 270}},
+		{"WindSpeed", got.WindSpeed, "6 mph"},
+		{"WindDirection", got.WindDirection, "Offshore"},
+		{"WindGustMph", got.WindGustMph, 11.0},
+		{"Source", got.Source, SOURCE_LIVE},
+		{"Attribution", got.Attribution, "Data from Surfline"},
+		{"TideEvents", len(got.TideEvents), 1},
+		{"Tide", got.Tide, "Falling, 1.2ft at " + time.Unix(now.Unix()+7200, 0).UTC().Format("3:04pm")},
+		{"Timestamp", got.Timestamp, now.Unix()},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+func TestSurflineBaseURL(t *testing.T) {
+	server := surflineStub(t, time.Now(), nil)
+	var hits atomic.Int32
+	stub := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		stub.ServeHTTP(w, r)
+	})
+
+	// A trailing slash on the configured URL is tolerated
+	t.Setenv("SURFLINE_BASE_URL", server.URL+"/")
+	setForTest(t, &surflineURL, surflineURL)
+	loadConfig()
+
+	provider := newSurflineProvider(server.Client())
+	if _, err := provider.Fetch(context.Background(), malibu); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if hits.Load() == 0 {
+		t.Error("the overridden host received no requests")
+	}
+
+	os.Unsetenv("SURFLINE_BASE_URL")
+	loadConfig()
+	if got := newSurflineProvider(http.DefaultClient).baseURL; got != surflineBaseURL {
+		t.Errorf("baseURL with SURFLINE_BASE_URL unset = %q, want %q", got, surflineBaseURL)
+	}
+}
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	next     http.RoundTripper
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return c.next.RoundTrip(r)
+}
+
+func TestSurflineProviderReusesClient(t *testing.T) {
+	server := surflineStub(t, time.Now(), nil)
+	transport := &countingTransport{next: server.Client().Transport}
+	client := &http.Client{Transport: transport}
+	provider := newSurflineProvider(client)
+	provider.baseURL = server.URL
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Fetch(context.Background(), malibu); err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+	}
+	if provider.client != client {
+		t.Error("provider replaced the client it was given")
+	}
+	// Two fetches of the wave, wind and tides resources
+	if got := transport.requests.Load(); got != 6 {
+		t.Errorf("shared client sent %d requests, want 6", got)
+	}
+}
+
+func TestNewUpstreamClient(t *testing.T) {
+	setForTest(t, &upstreamTimeout, 3*time.Second)
+	setForTest(t, &upstreamIdleConnTimeout, 30*time.Second)
+
+	client := newUpstreamClient()
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport = %T, want *http.Transport", client.Transport)
+	}
+	if client.Timeout != 3*time.Second || transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("timeouts = %v, %v, want the configured 3s and 30s", client.Timeout, transport.IdleConnTimeout)
+	}
+	if transport.MaxIdleConnsPerHost != UPSTREAM_MAX_IDLE_CONNS_PER_HOST || transport.MaxConnsPerHost != UPSTREAM_MAX_CONNS_PER_HOST {
+		t.Errorf("per-host limits = %d idle, %d total", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("upstream client shares http.DefaultTransport")
+	}
+}
+
+func TestSurflineProviderFetchErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		failures       map[string]int
+		wantStatus     int
+		wantRetryAfter time.Duration
+	}{
+		{"wave unavailable", map[string]int{"wave": http.StatusInternalServerError}, http.StatusInternalServerError, 0},
+		{"wind rate limited", map[string]int{"wind": http.StatusTooManyRequests}, http.StatusTooManyRequests, 7 * time.Second},
+		{"tides not found", map[string]int{"tides": http.StatusNotFound}, http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := surflineStub(t, time.Now(), tt.failures)
+
+			_, err := stubbedSurflineProvider(server).Fetch(context.Background(), malibu)
+			var statusErr *upstreamStatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("Fetch() error = %v, want an upstreamStatusError", err)
+			}
+			if statusErr.StatusCode != tt.wantStatus || statusErr.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("got status %d retry after %v, want %d and %v", statusErr.StatusCode, statusErr.RetryAfter, tt.wantStatus, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestSurflineProviderWaitsOutRetryAfter(t *testing.T) {
+	setForTest(t, &fetchTimeout, 5*time.Second)
+	stub := surflineStub(t, time.Now(), nil)
+	var requests atomic.Int32
+	var limitedAt atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Rate limit the very first request, then behave
+		if requests.Add(1) == 1 {
+			limitedAt.Store(time.Now().UnixNano())
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		stub.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	response, err := fetchWithRetry(context.Background(), stubbedSurflineProvider(server), malibu, 2)
+	if err != nil {
+		t.Fatalf("fetchWithRetry() error = %v", err)
+	}
+	if response.WaveHeightFt != 4.2 {
+		t.Errorf("WaveHeightFt = %v, want the retried fetch's 4.2", response.WaveHeightFt)
+	}
+	if waited := time.Since(time.Unix(0, limitedAt.Load())); waited < time.Second {
+		t.Errorf("retried %v after the 429, want at least the 1s asked for", waited)
+	}
+}
+
+func TestSurflineProviderFetchUndecodable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>maintenance</html>")
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := stubbedSurflineProvider(server).Fetch(context.Background(), malibu)
+	if !errors.Is(err, ErrUpstreamDecode) {
+		t.Errorf("Fetch() error = %v, want ErrUpstreamDecode", err)
+	}
+}
+
+func TestHandleForecastUndecodable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"wave":[{"timestamp":1,`)
+	}))
+	t.Cleanup(server.Close)
+	useProvider(t, stubbedSurflineProvider(server))
+
+	w := httptest.NewRecorder()
+	handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu, nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502: %s", w.Code, w.Body)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	if body["error"] != "Forecast source returned an invalid response" {
+		t.Errorf("error = %q", body["error"])
+	}
+	if _, found := forecastCache.Peek(malibu); found {
+		t.Error("a partially decoded forecast was cached")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_RATE_LIMIT_PER_MIN = 60
+
+// Buckets are pruned once this many clients are being tracked
+const maxTrackedClients = 10000
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a per-client token bucket allowing perMinute requests a
+// minute, with bursts of up to perMinute requests.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	buckets   map[string]*tokenBucket
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: perMinute,
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+// allow consumes a token for key. When no token is available it returns
+// false and how long until the next one is.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.perMinute)
+	refillPerSec := capacity / 60
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxTrackedClients {
+			l.prune(now)
+		}
+		bucket = &tokenBucket{tokens: capacity, lastSeen: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*refillPerSec)
+	bucket.lastSeen = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / refillPerSec * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune drops buckets that have refilled completely, since a fresh bucket
+// would behave the same way
+func (l *rateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) >= time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// middleware rejects clients that exceed the limit with 429 Too Many Requests.
+// A non-positive limit disables rate limiting.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	if l.perMinute <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.allow(clientIP(r), appClock.Now())
+		if !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeJSONError(w, http.StatusTooManyRequests, ERR_RATE_LIMITED, "Rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP identifies the caller by the connection's remote address. Only
+// when that is one of the TRUSTED_PROXIES is X-Forwarded-For consulted, and
+// then the right-most entry that isn't itself a trusted proxy is used:
+// anything further left was supplied by the client and could be forged.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !trustedProxy(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+// trustedProxy reports whether ip is one of the TRUSTED_PROXIES
+func trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
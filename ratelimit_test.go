@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"no proxies trusted", "", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"untrusted peer can't spoof", "10.0.0.0/8", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1", "10.0.0.1:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"right-most untrusted hop", "10.0.0.0/8", "10.0.0.1:5000", []string{"1.2.3.4, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"repeated headers", "10.0.0.0/8", "10.0.0.1:5000", []string{"1.2.3.4", "198.51.100.1"}, "198.51.100.1"},
+		{"all hops trusted", "10.0.0.0/8", "10.0.0.1:5000", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"trusted proxy without header", "10.0.0.0/8", "10.0.0.1:5000", nil, "10.0.0.1"},
+		{"ipv6 proxy", "::1", "[::1]:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"remote without port", "", "203.0.113.7", nil, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &trustedProxies, parseTrustedProxies(tt.trusted))
+
+			r := httptest.NewRequest(http.MethodGet, "/forecast", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"", nil},
+		{"10.0.0.0/8", []string{"10.0.0.0/8"}},
+		{"10.1.2.3/8, 192.168.1.5", []string{"10.0.0.0/8", "192.168.1.5/32"}},
+		{"::1,bogus", []string{"::1/128"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got := parseTrustedProxies(tt.raw)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseTrustedProxies(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+			for i, prefix := range got {
+				if prefix.String() != tt.want[i] {
+					t.Errorf("parseTrustedProxies(%q)[%d] = %s, want %s", tt.raw, i, prefix, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name     string
+		requests int
+		after    time.Duration
+		want     bool
+	}{
+		{"within burst", 60, 0, true},
+		{"burst exhausted", 61, 0, false},
+		{"refills over time", 61, time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newRateLimiter(60)
+			for i := 0; i < tt.requests-1; i++ {
+				limiter.allow("client", start)
+			}
+			ok, _ := limiter.allow("client", start.Add(tt.after))
+			if ok != tt.want {
+				t.Errorf("allow() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	useFakeClock(t, time.Unix(1_700_000_000, 0))
+	setForTest(t, &trustedProxies, nil)
+	handler := newRateLimiter(2).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest(http.MethodGet, "/forecast", nil)
+		// A fresh X-Forwarded-For per request must not earn a fresh bucket
+		r.Header.Set("X-Forwarded-For", "198.51.100."+strconv.Itoa(i))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, want)
+		}
+		if want == http.StatusTooManyRequests {
+			if _, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil {
+				t.Errorf("Retry-After = %q, want whole seconds", w.Header().Get("Retry-After"))
+			}
+		}
+	}
+}
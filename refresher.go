@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// runRefresher refetches spotIDs, and any spot with an alert on it, into the
+// cache straight away and then every interval, so popular spots are warm
+// before clients ask for them. It returns once ctx is cancelled.
+func runRefresher(ctx context.Context, interval time.Duration, spotIDs []string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshSpots(ctx, refreshTargets(spotIDs, alerts.SpotIDs()))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshTargets merges the prefetch list with the alerted spots, keeping
+// the prefetch order and dropping duplicates
+func refreshTargets(prefetch, alerted []string) []string {
+	seen := make(map[string]bool, len(prefetch)+len(alerted))
+	var spotIDs []string
+	for _, spotID := range append(append([]string{}, prefetch...), alerted...) {
+		if !seen[spotID] {
+			seen[spotID] = true
+			spotIDs = append(spotIDs, spotID)
+		}
+	}
+	return spotIDs
+}
+
+// refreshSpots fetches each known spot fresh, stores it in the cache and
+// checks the alerts on it
+func refreshSpots(ctx context.Context, spotIDs []string) {
+	for _, spotID := range spotIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, ok := knownSpots.Get(spotID); !ok {
+			slog.Warn("skipping refresh of unknown spot", "event", "refresh_skipped", "spotId", spotID)
+			continue
+		}
+		if disabledSpots[spotID] {
+			continue
+		}
+		response, err := getForecast(ctx, spotID, true)
+		if err != nil {
+			slog.Warn("background refresh failed", "event", "refresh_failed", "spotId", spotID, "error", err)
+			continue
+		}
+		alerts.Check(ctx, response)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRefreshTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefetch []string
+		alerted  []string
+		want     []string
+	}{
+		{"nothing", nil, nil, nil},
+		{"prefetch only", []string{malibu, huntington}, nil, []string{malibu, huntington}},
+		{"alerted only", nil, []string{tamarindo}, []string{tamarindo}},
+		{"merged in order", []string{huntington, malibu}, []string{malibu, tamarindo}, []string{huntington, malibu, tamarindo}},
+		{"duplicate prefetch", []string{malibu, malibu}, nil, []string{malibu}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefetch := append([]string{}, tt.prefetch...)
+			if got := refreshTargets(prefetch, tt.alerted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("refreshTargets() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(prefetch, append([]string{}, tt.prefetch...)) {
+				t.Errorf("refreshTargets() modified the prefetch list: %v", prefetch)
+			}
+		})
+	}
+}
+
+func TestRefreshSpots(t *testing.T) {
+	provider := newFakeProvider()
+	useProvider(t, provider)
+	useSpots(t)
+	setForTest(t, &disabledSpots, map[string]bool{huntington: true})
+	store, notifier := useAlerts(t)
+	alert, err := store.Add(Alert{SpotID: malibu, MinWaveFt: 3, Email: "surfer@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshSpots(context.Background(), []string{malibu, huntington, unknownSpotID})
+	refreshSpots(context.Background(), []string{malibu})
+
+	tests := []struct {
+		spotID    string
+		wantCalls int
+	}{
+		{malibu, 2},
+		{huntington, 0},
+		{unknownSpotID, 0},
+	}
+	for _, tt := range tests {
+		if got := provider.Calls(tt.spotID); got != tt.wantCalls {
+			t.Errorf("%s fetched %d times, want %d", tt.spotID, got, tt.wantCalls)
+		}
+	}
+	if _, found := forecastCache.Get(malibu); !found {
+		t.Error("refreshed forecast was not cached")
+	}
+	// The alert fires on the first refresh and holds through the second
+	if !reflect.DeepEqual(notifier.fired, []string{alert.ID}) {
+		t.Errorf("fired = %v, want [%s]", notifier.fired, alert.ID)
+	}
+}
+
+func TestRefreshSpotsCancelled(t *testing.T) {
+	provider := newFakeProvider()
+	useProvider(t, provider)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	refreshSpots(ctx, []string{malibu, huntington})
+	if got := provider.Calls(malibu) + provider.Calls(huntington); got != 0 {
+		t.Errorf("made %d fetches after cancellation", got)
+	}
+}
+
+func TestRunRefresherRefetchesEveryInterval(t *testing.T) {
+	fetched := make(chan struct{}, 10)
+	provider := newFakeProvider()
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		select {
+		case fetched <- struct{}{}:
+		default:
+		}
+		return fakeForecast(spotID, 3), nil
+	}
+	useProvider(t, provider)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		runRefresher(ctx, 10*time.Millisecond, []string{malibu})
+		close(done)
+	}()
+	// The first refresh runs straight away and the next after the interval
+	for i := 0; i < 2; i++ {
+		select {
+		case <-fetched:
+		case <-time.After(time.Second):
+			t.Fatalf("refresh %d did not happen", i+1)
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runRefresher did not stop after cancellation")
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// writeJSONResponse encodes v with an ETag derived from its content. When the
+// client already holds that representation it gets 304 Not Modified instead.
+// Passing pretty=true indents the output for reading in a browser, by the
+// amount given in indent=2|4|tab. Keys are written in the JSON_FIELD_STYLE.
+func writeJSONResponse(w http.ResponseWriter, r *http.Request, v interface{}) {
+	writeJSONStatus(w, r, http.StatusOK, v)
+}
+
+// writeJSONStatus is writeJSONResponse for any status, e.g. 201 Created
+func writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	writeJSONAs(w, r, status, "application/json", v)
+}
+
+// writeJSONAs is writeJSONStatus for JSON-based media types other than
+// application/json, such as application/geo+json
+func writeJSONAs(w http.ResponseWriter, r *http.Request, status int, contentType string, v interface{}) {
+	var body []byte
+	var err error
+	if jsonFieldStyle == FIELD_STYLE_SNAKE {
+		if v, err = snakeCaseKeys(v); err != nil {
+			slog.ErrorContext(r.Context(), "could not rename response fields", "event", "encode_failed", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Failed to encode response")
+			return
+		}
+	}
+	if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+		body, err = json.MarshalIndent(v, "", jsonIndent(r.URL.Query().Get("indent")))
+	} else {
+		body, err = json.Marshal(v)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "could not encode response", "event", "encode_failed", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Failed to encode response")
+		return
+	}
+	body = append(body, '\n')
+	writeBodyStatus(w, r, status, contentType, body)
+}
+
+// jsonIndent maps an indent parameter to the string MarshalIndent indents
+// with, defaulting to two spaces
+func jsonIndent(param string) string {
+	switch param {
+	case "4":
+		return "    "
+	case "tab":
+		return "\t"
+	default:
+		return "  "
+	}
+}
+
+// Machine-readable error codes, sent alongside the message in error bodies
+const (
+	ERR_MISSING_SPOT_ID      = "MISSING_SPOT_ID"
+	ERR_INVALID_SPOT_ID      = "INVALID_SPOT_ID"
+	ERR_UNKNOWN_SPOT         = "UNKNOWN_SPOT"
+	ERR_UNKNOWN_REGION       = "UNKNOWN_REGION"
+	ERR_MISSING_PARAMETER    = "MISSING_PARAMETER"
+	ERR_INVALID_PARAMETER    = "INVALID_PARAMETER"
+	ERR_INVALID_BODY         = "INVALID_BODY"
+	ERR_BODY_TOO_LARGE       = "BODY_TOO_LARGE"
+	ERR_QUERY_TOO_LONG       = "QUERY_TOO_LONG"
+	ERR_METHOD_NOT_ALLOWED   = "METHOD_NOT_ALLOWED"
+	ERR_UNAUTHORIZED         = "UNAUTHORIZED"
+	ERR_RATE_LIMITED         = "RATE_LIMITED"
+	ERR_SPOT_EXISTS          = "SPOT_EXISTS"
+	ERR_SPOT_DISABLED        = "SPOT_DISABLED"
+	ERR_UNKNOWN_ALERT        = "UNKNOWN_ALERT"
+	ERR_NOT_SUPPORTED        = "NOT_SUPPORTED"
+	ERR_UPSTREAM_ERROR       = "UPSTREAM_ERROR"
+	ERR_UPSTREAM_TIMEOUT     = "UPSTREAM_TIMEOUT"
+	ERR_UPSTREAM_UNAVAILABLE = "UPSTREAM_UNAVAILABLE"
+	ERR_INTERNAL             = "INTERNAL_ERROR"
+)
+
+// writeJSONError writes a JSON error body of the form
+// {"error":"...","code":"..."}, where code is one of the ERR_ constants
+func writeJSONError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg, "code": code})
+}
+
+// allowMethods answers 405 Method Not Allowed, listing the methods in an
+// Allow header, unless the request uses one of them
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSONError(w, http.StatusMethodNotAllowed, ERR_METHOD_NOT_ALLOWED, "Method not allowed")
+	return false
+}
+
+// writeDecodeError reports a request body that could not be decoded, with
+// 413 when it was cut off by limitMiddleware
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, ERR_BODY_TOO_LARGE, "Request body too large")
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, ERR_INVALID_BODY, "Invalid JSON body")
+}
+
+// writeForecastResponse writes a forecast or list of forecasts as JSON, or as
+// readable text when the client prefers text/plain. Last-Modified is the
+// newest forecast Timestamp.
+func writeForecastResponse(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Add("Vary", "Accept")
+	if lastModified := forecastLastModified(v); lastModified > 0 {
+		w.Header().Set("Last-Modified", time.Unix(lastModified, 0).UTC().Format(http.TimeFormat))
+	}
+	if !prefersPlainText(r.Header.Get("Accept")) {
+		if fields := parseFields(r.URL.Query().Get("fields")); len(fields) > 0 {
+			projected, err := projectFields(v, fields)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "could not project response", "event", "encode_failed", "error", err)
+				writeJSONError(w, http.StatusInternalServerError, ERR_INTERNAL, "Failed to encode response")
+				return
+			}
+			v = projected
+		}
+		writeJSONResponse(w, r, v)
+		return
+	}
+
+	var text string
+	switch forecasts := v.(type) {
+	case ForecastResponse:
+		text = formatTextForecast(forecasts)
+	case []ForecastResponse:
+		parts := make([]string, len(forecasts))
+		for i, forecast := range forecasts {
+			parts[i] = formatTextForecast(forecast)
+		}
+		text = strings.Join(parts, "\n")
+	default:
+		writeJSONResponse(w, r, v)
+		return
+	}
+	writeBody(w, r, "text/plain; charset=utf-8", []byte(text))
+}
+
+// parseFields splits a comma-separated fields parameter, dropping empty
+// entries
+func parseFields(param string) []string {
+	var fields []string
+	for _, field := range strings.Split(param, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// projectFields cuts a forecast, or each forecast in a list, down to the
+// given JSON keys, named in the configured field style. Names that aren't
+// keys of the forecast are ignored.
+func projectFields(v interface{}, fields []string) (interface{}, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		wanted[field] = true
+	}
+	project := func(object map[string]json.RawMessage) map[string]json.RawMessage {
+		projected := make(map[string]json.RawMessage, len(fields))
+		for key, value := range object {
+			if wanted[key] || (jsonFieldStyle == FIELD_STYLE_SNAKE && wanted[snakeCase(key)]) {
+				projected[key] = value
+			}
+		}
+		return projected
+	}
+
+	switch v.(type) {
+	case ForecastResponse:
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(body, &object); err != nil {
+			return nil, err
+		}
+		return project(object), nil
+	case []ForecastResponse:
+		var objects []map[string]json.RawMessage
+		if err := json.Unmarshal(body, &objects); err != nil {
+			return nil, err
+		}
+		for i, object := range objects {
+			objects[i] = project(object)
+		}
+		return objects, nil
+	}
+	return v, nil
+}
+
+// JSON key styles for forecast responses, chosen with JSON_FIELD_STYLE
+const (
+	FIELD_STYLE_CAMEL = "camel"
+	FIELD_STYLE_SNAKE = "snake"
+)
+
+// snakeCaseKeys re-encodes v with every object key, at any depth, converted
+// from camelCase to snake_case
+func snakeCaseKeys(v interface{}) (interface{}, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	var rename func(value interface{}) interface{}
+	rename = func(value interface{}) interface{} {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			renamed := make(map[string]interface{}, len(value))
+			for key, child := range value {
+				renamed[snakeCase(key)] = rename(child)
+			}
+			return renamed
+		case []interface{}:
+			for i, child := range value {
+				value[i] = rename(child)
+			}
+			return value
+		}
+		return value
+	}
+	return rename(decoded), nil
+}
+
+// snakeCase converts a camelCase name such as "waveHeightFt" to
+// "wave_height_ft". A run of capitals is kept together, so "energyKJ"
+// becomes "energy_kj".
+func snakeCase(name string) string {
+	var b strings.Builder
+	upperRun := false
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 && !upperRun {
+				b.WriteByte('_')
+			}
+			upperRun = true
+			r = unicode.ToLower(r)
+		} else {
+			upperRun = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// forecastLastModified returns the newest Timestamp among the forecasts in v
+func forecastLastModified(v interface{}) int64 {
+	switch forecasts := v.(type) {
+	case ForecastResponse:
+		return forecasts.Timestamp
+	case []ForecastResponse:
+		var newest int64
+		for _, forecast := range forecasts {
+			newest = max(newest, forecast.Timestamp)
+		}
+		return newest
+	}
+	return 0
+}
+
+// formatTextForecast renders a forecast as a few human-readable lines
+func formatTextForecast(resp ForecastResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", resp.Location)
+	if resp.Error != "" {
+		fmt.Fprintf(&b, "  Error:  %s\n", resp.Error)
+		return b.String()
+	}
+
+	wave := resp.WaveHeight
+	if resp.SwellCompass != "" {
+		wave += " (" + resp.SwellCompass + ")"
+	}
+	fmt.Fprintf(&b, "  Waves:  %s\n", wave)
+	fmt.Fprintf(&b, "  Wind:   %s %s\n", resp.WindSpeed, resp.WindDirection)
+	fmt.Fprintf(&b, "  Tide:   %s\n", resp.Tide)
+	fmt.Fprintf(&b, "  Rating: %s (%d/100)\n", resp.Rating, resp.Score)
+	return b.String()
+}
+
+// prefersPlainText reports whether an Accept header ranks text/plain above
+// application/json. JSON wins ties and is the default.
+func prefersPlainText(accept string) bool {
+	textQ, jsonQ := 0.0, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && name == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/plain":
+			textQ = math.Max(textQ, q)
+		case "application/json", "application/*", "*/*":
+			jsonQ = math.Max(jsonQ, q)
+		}
+	}
+	return textQ > jsonQ
+}
+
+// writeBody writes a response body of the given type with an ETag derived
+// from its content, answering 304 Not Modified when the client has it already.
+// If-Modified-Since is honored against any Last-Modified header already set.
+func writeBody(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	writeBodyStatus(w, r, http.StatusOK, contentType, body)
+}
+
+// writeBodyStatus is writeBody for any status. Only 200 responses can be
+// answered with 304. HEAD requests get the same headers, including
+// Content-Length, without the body.
+func writeBodyStatus(w http.ResponseWriter, r *http.Request, status int, contentType string, body []byte) {
+	etag := computeETag(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+
+	if status == http.StatusOK && notModified(r, w.Header(), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// notModified evaluates the conditional request headers. As RFC 9110
+// requires, If-Modified-Since is ignored when If-None-Match is present.
+func notModified(r *http.Request, header http.Header, etag string) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(ifModifiedSince)
+}
+
+// computeETag returns a strong ETag for body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison that RFC 9110 requires for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteBodyConditional(t *testing.T) {
+	body := []byte(`{"status":"ok"}`)
+	etag := computeETag(body)
+	lastModified := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"unconditional", nil, http.StatusOK},
+		{"matching etag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak etag", map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified},
+		{"etag in list", map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+		{"wildcard", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"stale etag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"etag wins over date", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+			writeBody(w, r, "application/json", body)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("ETag = %s, want %s", w.Header().Get("ETag"), etag)
+			}
+			if tt.want == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 carried a %d byte body", w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestForecastETag(t *testing.T) {
+	height := 3.0
+	provider := newFakeProvider()
+	provider.fetch = func(_ context.Context, spotID string) (ForecastResponse, error) {
+		forecast := fakeForecast(spotID, height)
+		forecast.Timestamp = 1_700_000_000
+		return forecast, nil
+	}
+	useProvider(t, provider)
+
+	get := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handleForecast(w, r)
+		return w
+	}
+
+	first := get("/forecast?spotId="+malibu, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q", first.Code, etag)
+	}
+	if again := get("/forecast?spotId="+malibu+"&bypassCache=true", ""); again.Header().Get("ETag") != etag {
+		t.Errorf("identical content got ETag %s, want %s", again.Header().Get("ETag"), etag)
+	}
+	if revalidated := get("/forecast?spotId="+malibu, etag); revalidated.Code != http.StatusNotModified {
+		t.Errorf("revalidation status = %d, want %d", revalidated.Code, http.StatusNotModified)
+	}
+
+	height = 5
+	changed := get("/forecast?spotId="+malibu+"&bypassCache=true", etag)
+	if changed.Code != http.StatusOK {
+		t.Errorf("changed content status = %d, want %d", changed.Code, http.StatusOK)
+	}
+	if changed.Header().Get("ETag") == etag {
+		t.Error("changed content kept the old ETag")
+	}
+}
+
+func TestForecastLastModified(t *testing.T) {
+	timestamp := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	provider := newFakeProvider()
+	provider.fetch = func(_ context.Context, spotID string) (ForecastResponse, error) {
+		forecast := fakeForecast(spotID, 3)
+		forecast.Timestamp = timestamp.Unix()
+		if spotID == huntington {
+			forecast.Timestamp = timestamp.Add(-time.Hour).Unix()
+		}
+		return forecast, nil
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		ifModifiedSince time.Time
+		want            int
+	}{
+		{"unconditional", "/forecast?spotId=" + malibu, time.Time{}, http.StatusOK},
+		{"not modified since", "/forecast?spotId=" + malibu, timestamp, http.StatusNotModified},
+		{"later date", "/forecast?spotId=" + malibu, timestamp.Add(time.Minute), http.StatusNotModified},
+		{"stale date", "/forecast?spotId=" + malibu, timestamp.Add(-time.Minute), http.StatusOK},
+		{"batch uses the newest", "/forecast?spotId=" + huntington + "," + malibu, timestamp.Add(-time.Minute), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, provider)
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if !tt.ifModifiedSince.IsZero() {
+				r.Header.Set("If-Modified-Since", tt.ifModifiedSince.Format(http.TimeFormat))
+			}
+			w := httptest.NewRecorder()
+			handleForecast(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if got, want := w.Header().Get("Last-Modified"), timestamp.Format(http.TimeFormat); got != want {
+				t.Errorf("Last-Modified = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// The same cached forecast is served compact by default and indented on
+// request, with identical content either way
+func TestForecastPretty(t *testing.T) {
+	useCache(t)
+
+	get := func(query string) []byte {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		return w.Body.Bytes()
+	}
+	compact := get("")
+	pretty := get("&pretty=true")
+
+	if bytes.Count(compact, []byte("\n")) != 1 {
+		t.Errorf("compact output spans several lines: %s", compact)
+	}
+	if !bytes.HasPrefix(pretty, []byte("{\n  \"")) {
+		t.Errorf("pretty output is not indented by two spaces: %s", pretty)
+	}
+	var recompacted bytes.Buffer
+	if err := json.Compact(&recompacted, pretty); err != nil {
+		t.Fatalf("compacting pretty output: %v", err)
+	}
+	if got, want := recompacted.String(), strings.TrimSuffix(string(compact), "\n"); got != want {
+		t.Errorf("pretty output differs from compact:\n%s\n%s", got, want)
+	}
+}
+
+func TestWriteForecastResponse(t *testing.T) {
+	forecast := fakeForecast(malibu, 3.5)
+	tests := []struct {
+		name     string
+		accept   string
+		v        interface{}
+		wantType string
+		wantBody []string
+	}{
+		{"default", "", forecast, "application/json", []string{`{"spotId":"` + malibu + `"`}},
+		{"json", "application/json", forecast, "application/json", []string{`{"spotId":"` + malibu + `"`}},
+		{"plain text", "text/plain", forecast, "text/plain; charset=utf-8", []string{"Malibu, CA\n  Waves:  3.5 ft at 12 seconds 210 degrees\n"}},
+		{"plain text list", "text/plain", []ForecastResponse{forecast, fakeForecast(huntington, 2)}, "text/plain; charset=utf-8", []string{"Malibu, CA\n", "Huntington Beach, CA\n"}},
+		{"json preferred", "text/plain;q=0.5, application/json", forecast, "application/json", []string{"{"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/forecast", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			writeForecastResponse(w, r, tt.v)
+
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body = %q, want it to contain %q", w.Body, want)
+				}
+			}
+		})
+	}
+}
+
+// jsonKeys returns the sorted keys of a JSON object
+func jsonKeys(t *testing.T, object map[string]json.RawMessage) []string {
+	t.Helper()
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPrettyIndent(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantPrefix string
+	}{
+		{"?pretty=true", "{\n  \""},
+		{"?pretty=true&indent=2", "{\n  \""},
+		{"?pretty=true&indent=4", "{\n    \""},
+		{"?pretty=true&indent=tab", "{\n\t\""},
+		{"?pretty=true&indent=3", "{\n  \""},
+		{"?indent=4", "{\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeJSONResponse(w, httptest.NewRequest(http.MethodGet, "/forecast"+tt.query, nil), fakeForecast(malibu, 3))
+			if !strings.HasPrefix(w.Body.String(), tt.wantPrefix) {
+				t.Errorf("body starts %q, want %q", w.Body.String()[:min(w.Body.Len(), 12)], tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestWriteJSONResponseFieldStyle(t *testing.T) {
+	value := map[string]interface{}{"waveHeightFt": 3.5, "swells": []map[string]int{{"periodSec": 12}}}
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{FIELD_STYLE_CAMEL, `{"swells":[{"periodSec":12}],"waveHeightFt":3.5}` + "\n"},
+		{FIELD_STYLE_SNAKE, `{"swells":[{"period_sec":12}],"wave_height_ft":3.5}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			setForTest(t, &jsonFieldStyle, tt.style)
+			w := httptest.NewRecorder()
+			writeJSONResponse(w, httptest.NewRequest(http.MethodGet, "/", nil), value)
+
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if w.Header().Get("ETag") != computeETag([]byte(tt.want)) {
+				t.Errorf("ETag = %s, want the body's hash", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestWriteJSONStatus(t *testing.T) {
+	setForTest(t, &jsonFieldStyle, FIELD_STYLE_SNAKE)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/spots", nil)
+	r.Header.Set("If-None-Match", "*")
+	writeJSONStatus(w, r, http.StatusCreated, SpotInfo{SpotID: malibu, Location: "Malibu, CA", Region: "CA"})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; only 200 may become 304", w.Code, http.StatusCreated)
+	}
+	if !strings.Contains(w.Body.String(), `"spot_id":"`+malibu+`"`) {
+		t.Errorf("body = %s, want snake_case keys", w.Body)
+	}
+}
+
+// Every endpoint carrying forecasts honours JSON_FIELD_STYLE
+func TestFieldStyleAcrossEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		url     string
+		want    string
+	}{
+		{"forecast", handleForecast, "/forecast?spotId=" + malibu, `"wave_height_ft"`},
+		{"history", handleHistory, "/forecast/history?spotId=" + malibu, `"wave_height_ft"`},
+		{"compare", handleCompare, "/forecast/compare?a=" + malibu + "&b=" + huntington, `"wave_height_ft"`},
+		{"summary", handleSummary, "/forecast/summary?spotId=" + malibu, `"wave_height_ft"`},
+		{"digest", handleDigest, "/forecast/digest?region=CR", `"best_spot_id"`},
+		{"spots", handleSpots, "/spots", `"spot_id"`},
+		{"geojson", handleSpotsGeoJSON, "/spots.geojson", `"spot_id"`},
+		{"cache stats", handleCacheStats, "/cache/stats", `"hit_ratio"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, newFakeProvider())
+			setForTest(t, &jsonFieldStyle, FIELD_STYLE_SNAKE)
+			forecastHistory.Add(malibu, fakeForecast(malibu, 3))
+
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			if body := w.Body.String(); !strings.Contains(body, tt.want) || strings.Contains(body, "waveHeightFt") {
+				t.Errorf("body = %s, want snake_case keys", body)
+			}
+		})
+	}
+}
+
+func TestProjectFieldsSnakeCase(t *testing.T) {
+	setForTest(t, &jsonFieldStyle, FIELD_STYLE_SNAKE)
+	projected, err := projectFields(fakeForecast(malibu, 3), []string{"wave_height_ft"})
+	if err != nil {
+		t.Fatalf("projectFields() error = %v", err)
+	}
+	if object := projected.(map[string]json.RawMessage); len(object) != 1 || string(object["waveHeightFt"]) != "3" {
+		t.Errorf("projectFields() = %v, want only waveHeightFt", object)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"spotId", "spot_id"},
+		{"waveHeightFt", "wave_height_ft"},
+		{"energyKJ", "energy_kj"},
+		{"UVIndex", "uvindex"},
+		{"location", "location"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snakeCase(tt.name); got != tt.want {
+				t.Errorf("snakeCase(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteForecastResponseFields(t *testing.T) {
+	forecast := fakeForecast(malibu, 3.5)
+	full, _ := json.Marshal(forecast)
+	var fullObject map[string]json.RawMessage
+	json.Unmarshal(full, &fullObject)
+	allKeys := strings.Join(jsonKeys(t, fullObject), ",")
+
+	tests := []struct {
+		name     string
+		query    string
+		v        interface{}
+		wantKeys string
+	}{
+		{"projected", "?fields=location,waveHeight", forecast, "location,waveHeight"},
+		{"unknown fields ignored", "?fields=location,bogus,,waveHeight", forecast, "location,waveHeight"},
+		{"projected list", "?fields=waveHeightFt", []ForecastResponse{forecast, fakeForecast(huntington, 2)}, "waveHeightFt"},
+		{"absent", "", forecast, allKeys},
+		{"empty", "?fields=", forecast, allKeys},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeForecastResponse(w, httptest.NewRequest(http.MethodGet, "/forecast"+tt.query, nil), tt.v)
+
+			var objects []map[string]json.RawMessage
+			if _, list := tt.v.([]ForecastResponse); list {
+				if err := json.Unmarshal(w.Body.Bytes(), &objects); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+			} else {
+				var object map[string]json.RawMessage
+				if err := json.Unmarshal(w.Body.Bytes(), &object); err != nil {
+					t.Fatalf("decoding body %q: %v", w.Body, err)
+				}
+				objects = append(objects, object)
+			}
+			for _, object := range objects {
+				if got := strings.Join(jsonKeys(t, object), ","); got != tt.wantKeys {
+					t.Errorf("keys = %s, want %s", got, tt.wantKeys)
+				}
+			}
+		})
+	}
+}
+
+func TestPrefersPlainText(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"text/plain", true},
+		{"application/json", false},
+		{"text/plain, application/json", false},
+		{"text/plain, */*;q=0.8", true},
+		{"TEXT/PLAIN", true},
+		{"text/html", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := prefersPlainText(tt.accept); got != tt.want {
+				t.Errorf("prefersPlainText(%q) = %v, want %v", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTextForecast(t *testing.T) {
+	tests := []struct {
+		name     string
+		forecast ForecastResponse
+		want     string
+	}{
+		{"failed", ForecastResponse{Location: "Malibu, CA", Error: "forecast fetch timed out"}, "Malibu, CA\n  Error:  forecast fetch timed out\n"},
+		{"conditions", ForecastResponse{Location: "Malibu, CA", WaveHeight: "3-4 ft", SwellCompass: "SW", WindSpeed: "5 mph", WindDirection: "Offshore", Tide: "Rising", Rating: "good", Score: 72},
+			"Malibu, CA\n  Waves:  3-4 ft (SW)\n  Wind:   5 mph Offshore\n  Tide:   Rising\n  Rating: good (72/100)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTextForecast(tt.forecast); got != tt.want {
+				t.Errorf("formatTextForecast() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowMethods(t *testing.T) {
+	tests := []struct {
+		method    string
+		want      bool
+		wantAllow string
+	}{
+		{http.MethodGet, true, ""},
+		{http.MethodHead, true, ""},
+		{http.MethodPost, false, "GET, HEAD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			got := allowMethods(w, httptest.NewRequest(tt.method, "/", nil), http.MethodGet, http.MethodHead)
+			if got != tt.want || w.Header().Get("Allow") != tt.wantAllow {
+				t.Errorf("allowMethods() = %v with Allow %q, want %v with %q", got, w.Header().Get("Allow"), tt.want, tt.wantAllow)
+			}
+			if !got && w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+			}
+		})
+	}
+}
+
+func TestWriteDecodeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     int
+		wantErr  string
+		wantCode string
+	}{
+		{"too large", fmt.Errorf("reading body: %w", &http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge, "Request body too large", ERR_BODY_TOO_LARGE},
+		{"malformed", json.Unmarshal([]byte("{"), &struct{}{}), http.StatusBadRequest, "Invalid JSON body", ERR_INVALID_BODY},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeDecodeError(w, tt.err)
+
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if w.Code != tt.want || body["error"] != tt.wantErr || body["code"] != tt.wantCode {
+				t.Errorf("got %d %q %s, want %d %q %s", w.Code, body["error"], body["code"], tt.want, tt.wantErr, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default number of attempts per upstream fetch, see FETCH_RETRIES
+const DEFAULT_FETCH_RETRIES = 3
+
+// Delay before the first retry, doubled for each one after it
+const FETCH_RETRY_BASE_DELAY = 200 * time.Millisecond
+
+// Longest Retry-After the fetch will wait out. Callers are blocked on the
+// fetch, so asking for more than this fails it instead.
+const MAX_RETRY_AFTER = 10 * time.Second
+
+// upstreamStatusError is returned when the forecast source answers with a
+// non-200 status
+type upstreamStatusError struct {
+	Resource   string
+	StatusCode int
+
+	// Wait the source asked for in Retry-After on a 429, zero if it gave none
+	RetryAfter time.Duration
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("surfline %s request returned status %d", e.Resource, e.StatusCode)
+}
+
+// transientError reports whether a failed fetch is worth retrying: timeouts,
+// 5xx and 429 responses are, other client errors are not
+func transientError(err error) bool {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// fetchWithRetry fetches a spot's forecast from provider, making up to
+// attempts tries with exponential backoff between them, or after the
+// Retry-After the source asked for when it rate limits us. The whole
+// sequence, waits included, must finish within fetchTimeout, so a hung source
+// fails the fetch once rather than once per attempt.
+func fetchWithRetry(ctx context.Context, provider ForecastProvider, spotID string, attempts int) (ForecastResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	delay := FETCH_RETRY_BASE_DELAY
+	for attempt := 1; ; attempt++ {
+		response, err := provider.Fetch(ctx, spotID)
+		if err == nil || attempt >= attempts || !transientError(err) || ctx.Err() != nil {
+			return response, err
+		}
+
+		wait := delay
+		var statusErr *upstreamStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			if statusErr.RetryAfter > MAX_RETRY_AFTER {
+				return response, err
+			}
+			wait = statusErr.RetryAfter
+		}
+		// No point sleeping past the deadline just to give up
+		if deadline, _ := ctx.Deadline(); time.Until(deadline) < wait {
+			return response, err
+		}
+
+		slog.WarnContext(ctx, "retrying forecast fetch", "event", "fetch_retry", "spotId", spotID, "attempt", attempt, "delay", wait, "error", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ForecastResponse{}, err
+		}
+		delay *= 2
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either as delay-seconds
+// or as an HTTP date, returning zero when it is missing or malformed
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
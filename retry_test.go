@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &upstreamStatusError{StatusCode: http.StatusBadGateway}, true},
+		{"rate limited", &upstreamStatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"not found", &upstreamStatusError{StatusCode: http.StatusNotFound}, false},
+		{"network timeout", fmt.Errorf("surfline wave request failed: %w", timeoutError{}), true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"canceled", context.Canceled, false},
+		{"decode", ErrUpstreamDecode, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transientError(tt.err); got != tt.want {
+				t.Errorf("transientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchWithRetry(t *testing.T) {
+	unavailable := &upstreamStatusError{Resource: "wave", StatusCode: http.StatusServiceUnavailable}
+	tests := []struct {
+		name      string
+		failures  []error
+		attempts  int
+		wantErr   error
+		wantCalls int
+	}{
+		{"first try", nil, 3, nil, 1},
+		{"recovers", []error{unavailable, unavailable}, 3, nil, 3},
+		{"gives up", []error{unavailable, unavailable, unavailable}, 3, unavailable, 3},
+		{"single attempt", []error{unavailable}, 1, unavailable, 1},
+		{"client error", []error{&upstreamStatusError{Resource: "wave", StatusCode: http.StatusNotFound}}, 3, errors.New("404"), 1},
+		{"retry after too long", []error{&upstreamStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}}, 3, errors.New("429"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+				if calls := provider.Calls(spotID); calls <= len(tt.failures) {
+					return ForecastResponse{}, tt.failures[calls-1]
+				}
+				return fakeForecast(spotID, 3), nil
+			}
+
+			response, err := fetchWithRetry(context.Background(), provider, malibu, tt.attempts)
+			if (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("fetchWithRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && response.SpotID != malibu {
+				t.Errorf("SpotID = %q, want %q", response.SpotID, malibu)
+			}
+			if got := provider.Calls(malibu); got != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// A hung provider fails once FETCH_TIMEOUT_MS is up, however many attempts
+// are left
+func TestFetchWithRetryHonoursRetryAfter(t *testing.T) {
+	provider := newFakeProvider()
+	provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+		if provider.Calls(spotID) == 1 {
+			return ForecastResponse{}, &upstreamStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 50 * time.Millisecond}
+		}
+		return fakeForecast(spotID, 3), nil
+	}
+
+	start := time.Now()
+	if _, err := fetchWithRetry(context.Background(), provider, malibu, 2); err != nil {
+		t.Fatalf("fetchWithRetry() error = %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("retried after %v, want at least the 50ms asked for", waited)
+	}
+}
+
+// A hung provider fails once FETCH_TIMEOUT_MS is up, however many attempts
+// are left
+func TestFetchWithRetryBoundedByFetchTimeout(t *testing.T) {
+	setForTest(t, &fetchTimeout, 100*time.Millisecond)
+	provider := newFakeProvider()
+	provider.hold = make(chan struct{})
+	defer close(provider.hold)
+
+	start := time.Now()
+	_, err := fetchWithRetry(context.Background(), provider, malibu, 5)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("fetchWithRetry() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("took %v, want about the 100ms fetch timeout", elapsed)
+	}
+	if got := provider.Calls(malibu); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// Spot time zones must resolve even where the system has no zoneinfo
+	_ "time/tzdata"
+)
+
+// Spot is a surf break known to the service
+type Spot struct {
+	SpotID   string  `json:"spotId"`
+	Location string  `json:"location"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+
+	// Compass bearing the beach faces, looking out to sea
+	BeachFacingDeg int `json:"beachFacingDeg"`
+
+	// IANA time zone of the spot, e.g. America/Los_Angeles. Empty means UTC.
+	Timezone string `json:"timezone"`
+
+	// State or country code the spot is grouped under, e.g. CA
+	Region string `json:"region"`
+
+	// How busy the spot tends to get, from 1 to 10
+	Popularity int `json:"popularity"`
+}
+
+// Spots available at startup
+var defaultSpots = []Spot{
+	{SpotID: "5842041f4e65fad6a7708814", Location: "Malibu, CA", Lat: 34.0360, Lon: -118.6780, BeachFacingDeg: 190, Timezone: "America/Los_Angeles", Region: "CA", Popularity: 9},
+	{SpotID: "5842041f4e65fad6a770883d", Location: "Huntington Beach, CA", Lat: 33.6553, Lon: -118.0040, BeachFacingDeg: 215, Timezone: "America/Los_Angeles", Region: "CA", Popularity: 8},
+	{SpotID: "5842041f4e65fad6a7709115", Location: "Tamarindo, CR", Lat: 10.2993, Lon: -85.8408, BeachFacingDeg: 270, Timezone: "America/Costa_Rica", Region: "CR", Popularity: 6},
+	{SpotID: "5842041f4e65fad6a7709117", Location: "Jaco, CR", Lat: 9.6140, Lon: -84.6296, BeachFacingDeg: 225, Timezone: "America/Costa_Rica", Region: "CR", Popularity: 5},
+	{SpotID: "5842041f4e65fad6a7709116", Location: "Dominical, CR", Lat: 9.2518, Lon: -83.8626, BeachFacingDeg: 220, Timezone: "America/Costa_Rica", Region: "CR", Popularity: 3},
+}
+
+var errSpotExists = errors.New("spot already registered")
+
+// spotRegistry holds the known spots and may be changed at runtime, so all
+// access goes through its lock
+type spotRegistry struct {
+	mu    sync.RWMutex
+	spots map[string]Spot
+}
+
+func newSpotRegistry(spots []Spot) *spotRegistry {
+	registry := &spotRegistry{spots: make(map[string]Spot, len(spots))}
+	for _, spot := range spots {
+		registry.spots[spot.SpotID] = spot
+	}
+	return registry
+}
+
+var knownSpots = newSpotRegistry(defaultSpots)
+
+// Get returns the spot with the given ID
+func (s *spotRegistry) Get(spotID string) (Spot, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	spot, ok := s.spots[spotID]
+	return spot, ok
+}
+
+// List returns every spot sorted by location name, then ID
+func (s *spotRegistry) List() []Spot {
+	s.mu.RLock()
+	spots := make([]Spot, 0, len(s.spots))
+	for _, spot := range s.spots {
+		spots = append(spots, spot)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(spots, func(i, j int) bool {
+		if spots[i].Location != spots[j].Location {
+			return spots[i].Location < spots[j].Location
+		}
+		return spots[i].SpotID < spots[j].SpotID
+	})
+	return spots
+}
+
+// Add registers a new spot, failing with errSpotExists if the ID is taken
+func (s *spotRegistry) Add(spot Spot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.spots[spot.SpotID]; ok {
+		return errSpotExists
+	}
+	s.spots[spot.SpotID] = spot
+	return nil
+}
+
+// Remove unregisters a spot, reporting whether it existed
+func (s *spotRegistry) Remove(spotID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.spots[spotID]; !ok {
+		return false
+	}
+	delete(s.spots, spotID)
+	return true
+}
+
+// spotLocation returns the display name of a known spot
+func spotLocation(spotID string) (string, bool) {
+	spot, ok := knownSpots.Get(spotID)
+	return spot.Location, ok
+}
+
+// spotSlug derives a URL-friendly name from a location, dropping the region
+// suffix: "Huntington Beach, CA" becomes "huntington-beach"
+func spotSlug(location string) string {
+	name, _, _ := strings.Cut(location, ",")
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// spotBySlug returns the known spot with the given slug. Should two locations
+// share a slug, the first in listing order wins.
+func spotBySlug(slug string) (Spot, bool) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	for _, spot := range knownSpots.List() {
+		if spotSlug(spot.Location) == slug {
+			return spot, true
+		}
+	}
+	return Spot{}, false
+}
+
+type SpotInfo struct {
+	SpotID   string `json:"spotId"`
+	Location string `json:"location"`
+	Region   string `json:"region"`
+}
+
+// validSpotID reports whether s looks like a Surfline spot ID, which is a
+// 24-character hex object ID
+func validSpotID(s string) bool {
+	if len(s) != 24 {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// listSpots returns every known spot sorted by location name.
+func listSpots() []SpotInfo {
+	registered := knownSpots.List()
+	spots := make([]SpotInfo, 0, len(registered))
+	for _, spot := range registered {
+		spots = append(spots, SpotInfo{SpotID: spot.SpotID, Location: spot.Location, Region: spot.Region})
+	}
+	return spots
+}
+
+// spotsInRegion returns the known spots in a region, ignoring case, sorted
+// like listSpots
+func spotsInRegion(region string) []SpotInfo {
+	matches := []SpotInfo{}
+	for _, spot := range listSpots() {
+		if strings.EqualFold(spot.Region, region) {
+			matches = append(matches, spot)
+		}
+	}
+	return matches
+}
+
+// listRegions returns the distinct regions of the known spots, sorted
+func listRegions() []string {
+	seen := make(map[string]bool)
+	regions := []string{}
+	for _, spot := range knownSpots.List() {
+		if spot.Region != "" && !seen[spot.Region] {
+			seen[spot.Region] = true
+			regions = append(regions, spot.Region)
+		}
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// spotRegion derives a region from a location's suffix, so "Malibu, CA" is
+// in CA
+func spotRegion(location string) string {
+	_, region, ok := strings.Cut(location, ",")
+	if !ok {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// handleRegions lists the regions spots are grouped under
+func handleRegions(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSONResponse(w, r, listRegions())
+}
+
+// searchSpots returns the known spots whose location name contains query,
+// ignoring case, sorted like listSpots
+func searchSpots(query string) []SpotInfo {
+	query = strings.ToLower(query)
+	matches := []SpotInfo{}
+	for _, spot := range listSpots() {
+		if strings.Contains(strings.ToLower(spot.Location), query) {
+			matches = append(matches, spot)
+		}
+	}
+	return matches
+}
+
+// handleSpotSearch finds spots by location name, e.g. /spots/search?q=mal
+func handleSpotSearch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_PARAMETER, "Missing q parameter")
+		return
+	}
+	writeJSONResponse(w, r, searchSpots(query))
+}
+
+// handleSpotDetails serves everything known about one spot at /spots/{id}
+func handleSpotDetails(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	spotID := strings.TrimPrefix(r.URL.Path, "/spots/")
+	if spotID == "" || strings.Contains(spotID, "/") {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spotId")
+		return
+	}
+	spot, ok := lookupSpot(r.Context(), spotID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spotId")
+		return
+	}
+	writeJSONResponse(w, r, spot)
+}
+
+type spotRegistration struct {
+	SpotID         string   `json:"spotId"`
+	Location       string   `json:"location"`
+	Lat            *float64 `json:"lat"`
+	Lon            *float64 `json:"lon"`
+	BeachFacingDeg int      `json:"beachFacingDeg"`
+	Timezone       string   `json:"timezone"`
+	Region         string   `json:"region"`
+	Popularity     int      `json:"popularity"`
+}
+
+// handleSpots lists spots on GET, optionally only those in ?region=.
+// Operators can register a spot with POST and remove one with
+// DELETE /spots?spotId=.., both behind requireAdmin.
+func handleSpots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		spots := listSpots()
+		if region := strings.TrimSpace(r.URL.Query().Get("region")); region != "" {
+			spots = spotsInRegion(region)
+		}
+		writeJSONResponse(w, r, spots)
+	case http.MethodPost:
+		requireAdmin(http.HandlerFunc(handleAddSpot)).ServeHTTP(w, r)
+	case http.MethodDelete:
+		requireAdmin(http.HandlerFunc(handleRemoveSpot)).ServeHTTP(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, ERR_METHOD_NOT_ALLOWED, "Method not allowed")
+	}
+}
+
+func handleAddSpot(w http.ResponseWriter, r *http.Request) {
+	var reg spotRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	switch {
+	case !validSpotID(reg.SpotID):
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "Invalid spotId format")
+		return
+	case strings.TrimSpace(reg.Location) == "":
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_PARAMETER, "Missing location")
+		return
+	case reg.Lat == nil || *reg.Lat < -90 || *reg.Lat > 90:
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Missing or invalid lat")
+		return
+	case reg.Lon == nil || *reg.Lon < -180 || *reg.Lon > 180:
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Missing or invalid lon")
+		return
+	}
+	if reg.Popularity < 0 || reg.Popularity > MAX_SPOT_POPULARITY {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid popularity")
+		return
+	}
+	// Default the zone here, once, rather than warning on every forecast
+	reg.Timezone = strings.TrimSpace(reg.Timezone)
+	if reg.Timezone == "" {
+		slog.WarnContext(r.Context(), "spot registered without a time zone, using UTC", "event", "timezone_missing", "spotId", reg.SpotID)
+		reg.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(reg.Timezone); err != nil {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_PARAMETER, "Invalid timezone")
+		return
+	}
+
+	spot := Spot{
+		SpotID:         reg.SpotID,
+		Location:       strings.TrimSpace(reg.Location),
+		Lat:            *reg.Lat,
+		Lon:            *reg.Lon,
+		BeachFacingDeg: reg.BeachFacingDeg,
+		Timezone:       reg.Timezone,
+		Region:         strings.ToUpper(strings.TrimSpace(reg.Region)),
+		Popularity:     reg.Popularity,
+	}
+	if spot.Region == "" {
+		spot.Region = spotRegion(spot.Location)
+	}
+	if err := knownSpots.Add(spot); err != nil {
+		writeJSONError(w, http.StatusConflict, ERR_SPOT_EXISTS, "Spot already registered")
+		return
+	}
+	// The ID may have been looked up before it was registered
+	unknownSpots.Delete(spot.SpotID)
+	slog.InfoContext(r.Context(), "spot registered", "event", "spot_added", "spotId", spot.SpotID)
+
+	writeJSONStatus(w, r, http.StatusCreated, SpotInfo{SpotID: spot.SpotID, Location: spot.Location, Region: spot.Region})
+}
+
+func handleRemoveSpot(w http.ResponseWriter, r *http.Request) {
+	spotID := r.URL.Query().Get("spotId")
+	if spotID == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing spotId parameter")
+		return
+	}
+	if !knownSpots.Remove(spotID) {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "Unknown spotId")
+		return
+	}
+
+	// Don't keep serving forecasts for a spot that no longer exists
+	forecastCache.Delete(spotID)
+	forecastHistory.Delete(spotID)
+	slog.InfoContext(r.Context(), "spot removed", "event", "spot_removed", "spotId", spotID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useSpots gives the test a registry holding only the default spots
+func useSpots(t *testing.T) *spotRegistry {
+	t.Helper()
+	setForTest(t, &knownSpots, newSpotRegistry(defaultSpots))
+	return knownSpots
+}
+
+func TestHandleSpotsList(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		wantCount  int
+		wantPretty bool
+	}{
+		{"every spot", "/spots", len(defaultSpots), false},
+		{"by region", "/spots?region=CA", 2, false},
+		{"region ignores case", "/spots?region=cr", 3, false},
+		{"unknown region", "/spots?region=XX", 0, false},
+		{"pretty", "/spots?pretty=true", len(defaultSpots), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSpots(t)
+			w := httptest.NewRecorder()
+			handleSpots(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Error("missing ETag")
+			}
+			var spots []SpotInfo
+			if err := json.Unmarshal(w.Body.Bytes(), &spots); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if len(spots) != tt.wantCount {
+				t.Fatalf("got %d spots, want %d", len(spots), tt.wantCount)
+			}
+			for _, spot := range spots {
+				if location, ok := spotLocation(spot.SpotID); !ok || location != spot.Location || spot.Region == "" {
+					t.Errorf("unexpected spot %+v", spot)
+				}
+			}
+			if !sort.SliceIsSorted(spots, func(i, j int) bool { return spots[i].Location < spots[j].Location }) {
+				t.Errorf("spots are not sorted by location: %+v", spots)
+			}
+			if pretty := strings.Contains(w.Body.String(), "\n  "); pretty != tt.wantPretty {
+				t.Errorf("indented = %v, want %v", pretty, tt.wantPretty)
+			}
+		})
+	}
+}
+
+func TestHandleRegions(t *testing.T) {
+	registry := useSpots(t)
+	registry.Add(Spot{SpotID: "aaaaaaaaaaaaaaaaaaaaaaaa", Location: "Uluwatu, ID", Region: "ID"})
+
+	w := httptest.NewRecorder()
+	handleRegions(w, httptest.NewRequest(http.MethodGet, "/regions", nil))
+
+	var regions []string
+	if err := json.Unmarshal(w.Body.Bytes(), &regions); err != nil {
+		t.Fatalf("decoding body %q: %v", w.Body, err)
+	}
+	if got := strings.Join(regions, ","); got != "CA,CR,ID" {
+		t.Errorf("regions = %s, want CA,CR,ID", got)
+	}
+}
+
+func TestSpotRegion(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"Malibu, CA", "CA"},
+		{"Uluwatu, id", "ID"},
+		{"Pipeline", ""},
+	}
+	for _, tt := range tests {
+		if got := spotRegion(tt.location); got != tt.want {
+			t.Errorf("spotRegion(%q) = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestHandleSpotSearch(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantIDs []string
+	}{
+		{"match", "?q=mal", http.StatusOK, []string{malibu}},
+		{"case insensitive", "?q=TAMA", http.StatusOK, []string{tamarindo}},
+		{"several matches", "?q=,%20cr", http.StatusOK, []string{"5842041f4e65fad6a7709116", "5842041f4e65fad6a7709117", tamarindo}},
+		{"no match", "?q=pipeline", http.StatusOK, []string{}},
+		{"missing query", "", http.StatusBadRequest, nil},
+		{"blank query", "?q=%20", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSpots(t)
+			w := httptest.NewRecorder()
+			handleSpotSearch(w, httptest.NewRequest(http.MethodGet, "/spots/search"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			// No match is an empty array rather than null
+			if len(tt.wantIDs) == 0 && strings.TrimSpace(w.Body.String()) != "[]" {
+				t.Errorf("body = %s, want []", w.Body)
+			}
+			var spots []SpotInfo
+			if err := json.Unmarshal(w.Body.Bytes(), &spots); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			var ids []string
+			for _, spot := range spots {
+				ids = append(ids, spot.SpotID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("spots = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSpotBySlug(t *testing.T) {
+	tests := []struct {
+		slug   string
+		want   string
+		wantOK bool
+	}{
+		{"malibu", malibu, true},
+		{"huntington-beach", huntington, true},
+		{" Tamarindo ", tamarindo, true},
+		{"huntington", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			useSpots(t)
+			spot, ok := spotBySlug(tt.slug)
+			if ok != tt.wantOK || spot.SpotID != tt.want {
+				t.Errorf("spotBySlug(%q) = %s, %v, want %s, %v", tt.slug, spot.SpotID, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidSpotID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{malibu, true},
+		{"5842041F4E65FAD6A7708814", true},
+		{"5842041f4e65fad6a770881", false},
+		{"5842041f4e65fad6a77088144", false},
+		{"5842041f4e65fad6a770881z", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := validSpotID(tt.id); got != tt.want {
+				t.Errorf("validSpotID(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleForecastInvalidSpotID(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  string
+	}{
+		{"too short", "5842041f"},
+		{"not hex", "5842041f4e65fad6a770881z"},
+		{"one bad id in a batch", malibu + ",nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			useProvider(t, provider)
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+tt.ids, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if body["error"] != "invalid spotId format" {
+				t.Errorf("error = %q, want %q", body["error"], "invalid spotId format")
+			}
+			if provider.Calls(malibu) != 0 || len(forecastCache.items) != 0 {
+				t.Error("an invalid request reached the provider or cache")
+			}
+		})
+	}
+}
+
+func TestHandleSpotsRegistration(t *testing.T) {
+	const newSpot = "aaaaaaaaaaaaaaaaaaaaaaaa"
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		body        string
+		token       string
+		want        int
+		wantPresent bool
+	}{
+		{"add", http.MethodPost, "/spots", `{"spotId":"` + newSpot + `","location":"Uluwatu, ID","lat":-8.8,"lon":115.1}`, "s3cret", http.StatusCreated, true},
+		{"duplicate", http.MethodPost, "/spots", `{"spotId":"` + malibu + `","location":"Malibu, CA","lat":34,"lon":-118}`, "s3cret", http.StatusConflict, false},
+		{"invalid spotId", http.MethodPost, "/spots", `{"spotId":"nope","location":"Uluwatu, ID","lat":-8.8,"lon":115.1}`, "s3cret", http.StatusBadRequest, false},
+		{"with timezone", http.MethodPost, "/spots", `{"spotId":"` + newSpot + `","location":"Uluwatu, ID","lat":-8.8,"lon":115.1,"timezone":"Asia/Makassar"}`, "s3cret", http.StatusCreated, true},
+		{"invalid timezone", http.MethodPost, "/spots", `{"spotId":"` + newSpot + `","location":"Uluwatu, ID","lat":-8.8,"lon":115.1,"timezone":"Bali/Uluwatu"}`, "s3cret", http.StatusBadRequest, false},
+		{"invalid popularity", http.MethodPost, "/spots", `{"spotId":"` + newSpot + `","location":"Uluwatu, ID","lat":-8.8,"lon":115.1,"popularity":11}`, "s3cret", http.StatusBadRequest, false},
+		{"missing lat", http.MethodPost, "/spots", `{"spotId":"` + newSpot + `","location":"Uluwatu, ID","lon":115.1}`, "s3cret", http.StatusBadRequest, false},
+		{"not admin", http.MethodPost, "/spots", `{"spotId":"` + newSpot + `","location":"Uluwatu, ID","lat":-8.8,"lon":115.1}`, "guess", http.StatusUnauthorized, false},
+		{"delete", http.MethodDelete, "/spots?spotId=" + malibu, "", "s3cret", http.StatusNoContent, false},
+		{"delete unknown", http.MethodDelete, "/spots?spotId=" + newSpot, "", "s3cret", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := useSpots(t)
+			cache := useCache(t)
+			cache.Set(malibu, fakeForecast(malibu, 3), time.Now().Unix()+60)
+			setForTest(t, &adminToken, "s3cret")
+
+			r := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			handleSpots(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if _, ok := registry.Get(newSpot); ok != tt.wantPresent {
+				t.Errorf("new spot registered = %v, want %v", ok, tt.wantPresent)
+			}
+			if spot, _ := registry.Get(newSpot); tt.name == "add" && (spot.Timezone != "UTC" || spot.Region != "ID") {
+				t.Errorf("Timezone, Region = %q, %q, want UTC and ID derived from the location", spot.Timezone, spot.Region)
+			}
+			if tt.name == "delete" {
+				if _, ok := registry.Get(malibu); ok {
+					t.Error("deleted spot is still registered")
+				}
+				if _, ok := cache.Get(malibu); ok {
+					t.Error("deleted spot is still cached")
+				}
+			}
+		})
+	}
+}
+
+func TestHandleSpotDetails(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    int
+		wantErr string
+	}{
+		{"known spot", "/spots/" + malibu, http.StatusOK, ""},
+		{"unknown spot", "/spots/" + unknownSpotID, http.StatusNotFound, "unknown spotId"},
+		{"missing id", "/spots/", http.StatusNotFound, "unknown spotId"},
+		{"nested path", "/spots/a/b", http.StatusNotFound, "unknown spotId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleSpotDetails(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if tt.wantErr != "" {
+				if body["error"] != tt.wantErr {
+					t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+				}
+				return
+			}
+			want := map[string]any{
+				"spotId":         malibu,
+				"location":       "Malibu, CA",
+				"lat":            34.036,
+				"lon":            -118.678,
+				"beachFacingDeg": 190.0,
+				"timezone":       "America/Los_Angeles",
+			}
+			for key, value := range want {
+				if body[key] != value {
+					t.Errorf("%s = %v, want %v", key, body[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultSpotTimezones(t *testing.T) {
+	for _, spot := range defaultSpots {
+		if _, err := time.LoadLocation(spot.Timezone); err != nil || spot.Timezone == "" {
+			t.Errorf("%s timezone %q: %v", spot.Location, spot.Timezone, err)
+		}
+	}
+}
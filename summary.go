@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// ForecastSummary is the condensed forecast served by /forecast/summary
+type ForecastSummary struct {
+	Location     string  `json:"location"`
+	WaveHeightFt float64 `json:"waveHeightFt"`
+	Rating       string  `json:"rating"`
+}
+
+// summarizeForecast cuts a forecast down to its headline numbers
+func summarizeForecast(response ForecastResponse) ForecastSummary {
+	return ForecastSummary{
+		Location:     response.Location,
+		WaveHeightFt: response.WaveHeightFt,
+		Rating:       response.Rating,
+	}
+}
+
+// DigestSpot is one spot's headline numbers within a ForecastDigest. Unlike
+// a ForecastSummary it names the spot and says why it couldn't be served.
+type DigestSpot struct {
+	SpotID       string  `json:"spotId"`
+	Location     string  `json:"location"`
+	WaveHeightFt float64 `json:"waveHeightFt"`
+	Rating       string  `json:"rating"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// digestSpot cuts a forecast down to its entry in a digest
+func digestSpot(response ForecastResponse) DigestSpot {
+	return DigestSpot{
+		SpotID:       response.SpotID,
+		Location:     response.Location,
+		WaveHeightFt: response.WaveHeightFt,
+		Rating:       response.Rating,
+		Error:        response.Error,
+	}
+}
+
+// handleSummary serves just the headline numbers of a spot's forecast, for
+// clients that can't afford the full payload
+func handleSummary(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	spotID := r.URL.Query().Get("spotId")
+	if spotID == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing spotId parameter")
+		return
+	}
+	if !validSpotID(spotID) {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+		return
+	}
+	if _, ok := lookupSpot(r.Context(), spotID); !ok {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spotId")
+		return
+	}
+
+	response, err := getForecast(r.Context(), spotID, false)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error fetching forecast", "event", "fetch_failed", "spotId", spotID, "error", err)
+		writeFetchError(w, err)
+		return
+	}
+	setCacheControl(w, []string{spotID}, false)
+	setCacheStatus(w, response)
+	writeJSONResponse(w, r, summarizeForecast(response))
+}
+
+// ForecastDigest summarizes every spot in a region, served by
+// /forecast/digest
+type ForecastDigest struct {
+	ApiVersion string       `json:"apiVersion"`
+	Region     string       `json:"region"`
+	Spots      []DigestSpot `json:"spots"`
+
+	// Highest-scoring spot, see bestForecast. Empty when no spot could be
+	// served.
+	BestSpotID string `json:"bestSpotId"`
+}
+
+// handleDigest serves a summary of each spot in ?region= along with the one
+// with the best conditions, for planning a trip around a region
+func handleDigest(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	region := strings.TrimSpace(r.URL.Query().Get("region"))
+	if region == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_PARAMETER, "Missing region parameter")
+		return
+	}
+	spots := spotsInRegion(region)
+	if len(spots) == 0 {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_REGION, "unknown region")
+		return
+	}
+
+	spotIDs := make([]string, len(spots))
+	for i, spot := range spots {
+		spotIDs[i] = spot.SpotID
+	}
+	forecasts := getForecasts(r.Context(), spotIDs, false, UNITS_IMPERIAL)
+
+	digest := ForecastDigest{ApiVersion: API_VERSION, Region: spots[0].Region, Spots: make([]DigestSpot, len(forecasts))}
+	for i, forecast := range forecasts {
+		digest.Spots[i] = digestSpot(forecast)
+	}
+	if best, ok := bestForecast(forecasts); ok {
+		digest.BestSpotID = best.SpotID
+	}
+	setCacheControl(w, spotIDs, false)
+	writeJSONResponse(w, r, digest)
+}
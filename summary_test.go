@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHandleSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantErr string
+	}{
+		{"known spot", "?spotId=" + malibu, http.StatusOK, ""},
+		{"missing spot", "", http.StatusBadRequest, "Missing spotId parameter"},
+		{"invalid spot", "?spotId=nope", http.StatusBadRequest, "invalid spotId format"},
+		{"unknown spot", "?spotId=" + unknownSpotID, http.StatusNotFound, "unknown spotId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProvider(t, newFakeProvider())
+
+			w := httptest.NewRecorder()
+			handleSummary(w, httptest.NewRequest(http.MethodGet, "/forecast/summary"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			// The summary is deliberately small, so nothing else may creep in
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if tt.wantErr != "" {
+				if body["error"] != tt.wantErr {
+					t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+				}
+				return
+			}
+			var keys []string
+			for key := range body {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if got, want := strings.Join(keys, ","), "location,rating,waveHeightFt"; got != want {
+				t.Errorf("keys = %s, want %s", got, want)
+			}
+			if body["location"] != "Malibu, CA" || body["waveHeightFt"] != 3.5 || body["rating"] == "" {
+				t.Errorf("body = %v", body)
+			}
+			if got := w.Header().Get("X-Cache"); got != "miss" {
+				t.Errorf("X-Cache = %q, want miss", got)
+			}
+		})
+	}
+}
+
+func TestHandleDigest(t *testing.T) {
+	heights := map[string]float64{
+		tamarindo:                  3,
+		"5842041f4e65fad6a7709117": 6, // Jaco
+	}
+	tests := []struct {
+		name         string
+		query        string
+		want         int
+		wantCode     string
+		wantRegion   string
+		wantSpots    int
+		wantBestSpot string
+	}{
+		{"region", "?region=CR", http.StatusOK, "", "CR", 3, "5842041f4e65fad6a7709117"},
+		{"lowercase region", "?region=cr", http.StatusOK, "", "CR", 3, "5842041f4e65fad6a7709117"},
+		{"missing region", "", http.StatusBadRequest, ERR_MISSING_PARAMETER, "", 0, ""},
+		{"unknown region", "?region=ZZ", http.StatusNotFound, ERR_UNKNOWN_REGION, "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			// Dominical is down, so its entry carries the error instead
+			provider.fetch = func(ctx context.Context, spotID string) (ForecastResponse, error) {
+				height, ok := heights[spotID]
+				if !ok {
+					return ForecastResponse{}, errors.New("upstream unavailable")
+				}
+				return fakeForecast(spotID, height), nil
+			}
+			useProvider(t, provider)
+
+			w := httptest.NewRecorder()
+			handleDigest(w, httptest.NewRequest(http.MethodGet, "/forecast/digest"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantCode != "" {
+				if got := errorCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %q, want %q", got, tt.wantCode)
+				}
+				return
+			}
+			var digest ForecastDigest
+			if err := json.Unmarshal(w.Body.Bytes(), &digest); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if digest.ApiVersion != API_VERSION || digest.Region != tt.wantRegion || digest.BestSpotID != tt.wantBestSpot {
+				t.Errorf("digest = %+v", digest)
+			}
+			if len(digest.Spots) != tt.wantSpots {
+				t.Fatalf("got %d spots, want %d", len(digest.Spots), tt.wantSpots)
+			}
+			for _, spot := range digest.Spots {
+				if _, ok := heights[spot.SpotID]; ok == (spot.Error != "") {
+					t.Errorf("spot %s error = %q", spot.SpotID, spot.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Julian dates of the J2000 epoch and of the Unix epoch
+const (
+	julianJ2000 = 2451545.0
+	julianUnix  = 2440587.5
+)
+
+// sunTimes returns sunrise and sunset on the local day containing t at the given
+// coordinates, using the sunrise equation. It reports false during polar day
+// or night, when the sun doesn't cross the horizon.
+func sunTimes(lat, lon float64, t time.Time) (sunrise, sunset time.Time, ok bool) {
+	// Shift to mean solar time so the day is the spot's, not UTC's
+	local := t.UTC().Add(time.Duration(lon / 15 * float64(time.Hour)))
+	year, month, day := local.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	julianDay := math.Ceil(float64(midnight.Unix())/86400 + julianUnix - julianJ2000 + 0.0008)
+
+	meanSolarNoon := julianDay - lon/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarNoon, 360)
+	center := 1.9148*sinDeg(anomaly) + 0.02*sinDeg(2*anomaly) + 0.0003*sinDeg(3*anomaly)
+	longitude := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := julianJ2000 + meanSolarNoon + 0.0053*sinDeg(anomaly) - 0.0069*sinDeg(2*longitude)
+
+	sinDeclination := sinDeg(longitude) * sinDeg(23.4397)
+	cosDeclination := math.Cos(math.Asin(sinDeclination))
+	// -0.833 degrees allows for refraction and the sun's apparent radius
+	cosHourAngle := (sinDeg(-0.833) - sinDeg(lat)*sinDeclination) / (math.Cos(lat*math.Pi/180) * cosDeclination)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+
+	return julianToTime(transit - hourAngle/360), julianToTime(transit + hourAngle/360), true
+}
+
+func sinDeg(deg float64) float64 {
+	return math.Sin(deg * math.Pi / 180)
+}
+
+func julianToTime(julian float64) time.Time {
+	return time.Unix(int64(math.Round((julian-julianUnix)*86400)), 0)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		name        string
+		lat         float64
+		lon         float64
+		at          time.Time
+		wantOK      bool
+		wantSunrise time.Time
+		wantSunset  time.Time
+	}{
+		{
+			name: "malibu midsummer", lat: 34.03, lon: -118.78,
+			at:          time.Date(2024, 6, 21, 12, 0, 0, 0, losAngeles),
+			wantOK:      true,
+			wantSunrise: time.Date(2024, 6, 21, 5, 44, 0, 0, losAngeles),
+			wantSunset:  time.Date(2024, 6, 21, 20, 12, 0, 0, losAngeles),
+		},
+		{
+			// Late evening is still the same local day, although UTC has moved on
+			name: "malibu late evening", lat: 34.03, lon: -118.78,
+			at:          time.Date(2024, 6, 21, 22, 0, 0, 0, losAngeles),
+			wantOK:      true,
+			wantSunrise: time.Date(2024, 6, 21, 5, 44, 0, 0, losAngeles),
+			wantSunset:  time.Date(2024, 6, 21, 20, 12, 0, 0, losAngeles),
+		},
+		{name: "polar day", lat: 78, lon: 15, at: time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC)},
+		{name: "polar night", lat: 78, lon: 15, at: time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, sunset, ok := sunTimes(tt.lat, tt.lon, tt.at)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !sunrise.Before(sunset) {
+				t.Errorf("sunrise %v is not before sunset %v", sunrise, sunset)
+			}
+			// The sunrise equation is good to within a few minutes
+			if d := sunrise.Sub(tt.wantSunrise).Abs(); d > 10*time.Minute {
+				t.Errorf("sunrise = %v, want about %v", sunrise.In(losAngeles), tt.wantSunrise)
+			}
+			if d := sunset.Sub(tt.wantSunset).Abs(); d > 10*time.Minute {
+				t.Errorf("sunset = %v, want about %v", sunset.In(losAngeles), tt.wantSunset)
+			}
+		})
+	}
+}
+
+func TestEnrichForecastSunTimes(t *testing.T) {
+	tests := []struct {
+		name   string
+		spotID string
+		want   bool
+	}{
+		{"known spot", malibu, true},
+		{"no coordinates", unknownSpotID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := fakeForecast(tt.spotID, 3)
+			resp.Timestamp = time.Date(2024, 6, 21, 19, 0, 0, 0, time.UTC).Unix()
+			enrichForecast(&resp)
+
+			if !tt.want {
+				if resp.Sunrise != 0 || resp.Sunset != 0 {
+					t.Errorf("Sunrise, Sunset = %d, %d, want zero", resp.Sunrise, resp.Sunset)
+				}
+				return
+			}
+			if resp.Sunrise == 0 || resp.Sunrise >= resp.Sunset {
+				t.Fatalf("Sunrise, Sunset = %d, %d", resp.Sunrise, resp.Sunset)
+			}
+			// Both fall within a day of the forecast
+			for _, at := range []int64{resp.Sunrise, resp.Sunset} {
+				if d := at - resp.Timestamp; d < -86400 || d > 86400 {
+					t.Errorf("%v is not on the forecast's day", time.Unix(at, 0).UTC())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMockTideEvents(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		spotID string
+		want   int
+	}{
+		{"malibu", malibu, TIDE_EVENT_COUNT},
+		{"tamarindo", tamarindo, TIDE_EVENT_COUNT},
+		{"no tide data", unknownSpotID, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := mockTideEvents(tt.spotID, now)
+			if len(events) != tt.want {
+				t.Fatalf("got %d events, want %d", len(events), tt.want)
+			}
+			for i, event := range events {
+				if i == 0 {
+					if at := time.Unix(event.Time, 0); !at.After(now) || at.Sub(now) > tideHalfCycle {
+						t.Errorf("first turn at %v, want within a half cycle after %v", at, now)
+					}
+					continue
+				}
+				previous := events[i-1]
+				if event.Type == previous.Type {
+					t.Errorf("events %d and %d are both %s", i-1, i, event.Type)
+				}
+				if got := time.Duration(event.Time-previous.Time) * time.Second; got != tideHalfCycle {
+					t.Errorf("turns %d apart, want %v", got, tideHalfCycle)
+				}
+			}
+		})
+	}
+}
+
+func TestSpotTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		want     string
+	}{
+		{"costa rica", "America/Costa_Rica", "America/Costa_Rica"},
+		{"missing", "", "UTC"},
+		{"invalid", "Not/A_Zone", "UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spotTimezone(Spot{SpotID: tamarindo, Timezone: tt.timezone}).String(); got != tt.want {
+				t.Errorf("spotTimezone() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
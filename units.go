@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	UNITS_IMPERIAL = "imperial"
+	UNITS_METRIC   = "metric"
+)
+
+var (
+	feetPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*ft\b`)
+	mphPattern  = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*mph\b`)
+
+	// A range such as "3-4.5 ft", which gives its unit only once
+	feetRangePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)-(\d+(?:\.\d+)?)\s*ft\b`)
+)
+
+func ftToM(ft float64) float64 {
+	return ft * 0.3048
+}
+
+func mphToKmh(mph float64) float64 {
+	return mph * 1.609344
+}
+
+func fToC(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// toMetric converts an imperial forecast to metric units, rewriting both the
+// numeric fields and the display strings.
+func toMetric(resp ForecastResponse) ForecastResponse {
+	resp.Units = UNITS_METRIC
+	resp.WaveHeight = convertUnits(resp.WaveHeight)
+	resp.WindSpeed = convertUnits(resp.WindSpeed)
+	resp.Tide = convertUnits(resp.Tide)
+	resp.WaveHeightFt = ftToM(resp.WaveHeightFt)
+	resp.WaveHeightMinFt = ftToM(resp.WaveHeightMinFt)
+	resp.WaveHeightMaxFt = ftToM(resp.WaveHeightMaxFt)
+	resp.WindSpeedMph = mphToKmh(resp.WindSpeedMph)
+	resp.WindGustMph = mphToKmh(resp.WindGustMph)
+	// Zero means the source reported no temperature, so it stays zero
+	if resp.WaterTempF != 0 {
+		resp.WaterTempF = fToC(resp.WaterTempF)
+	}
+	if resp.AirTempF != 0 {
+		resp.AirTempF = fToC(resp.AirTempF)
+	}
+
+	swells := make([]Swell, len(resp.Swells))
+	for i, swell := range resp.Swells {
+		swell.HeightFt = ftToM(swell.HeightFt)
+		swells[i] = swell
+	}
+	resp.Swells = swells
+
+	tideEvents := make([]TideEvent, len(resp.TideEvents))
+	for i, event := range resp.TideEvents {
+		event.HeightFt = ftToM(event.HeightFt)
+		tideEvents[i] = event
+	}
+	resp.TideEvents = tideEvents
+	return resp
+}
+
+// convertUnits rewrites every "<n> ft", "<n>-<m> ft" and "<n> mph" in s to
+// meters and km/h
+func convertUnits(s string) string {
+	s = feetRangePattern.ReplaceAllStringFunc(s, func(match string) string {
+		bounds := feetRangePattern.FindStringSubmatch(match)
+		minFt, _ := strconv.ParseFloat(bounds[1], 64)
+		maxFt, _ := strconv.ParseFloat(bounds[2], 64)
+		return fmt.Sprintf("%.2f-%.2f m", ftToM(minFt), ftToM(maxFt))
+	})
+	s = feetPattern.ReplaceAllStringFunc(s, func(match string) string {
+		ft, _ := strconv.ParseFloat(feetPattern.FindStringSubmatch(match)[1], 64)
+		return fmt.Sprintf("%.2f m", ftToM(ft))
+	})
+	return mphPattern.ReplaceAllStringFunc(s, func(match string) string {
+		mph, _ := strconv.ParseFloat(mphPattern.FindStringSubmatch(match)[1], 64)
+		return fmt.Sprintf("%.0f km/h", mphToKmh(mph))
+	})
+}
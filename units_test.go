@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"4 ft at 12 seconds 215 degrees", "1.22 m at 12 seconds 215 degrees"},
+		{"3-4.5 ft at 12 seconds 215 degrees", "0.91-1.37 m at 12 seconds 215 degrees"},
+		{"5 mph", "8 km/h"},
+		{"Rising, 2.5ft at 10:30am", "Rising, 0.76 m at 10:30am"},
+		{"Unknown", "Unknown"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := convertUnits(tt.s); got != tt.want {
+				t.Errorf("convertUnits(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleForecastUnits(t *testing.T) {
+	tests := []struct {
+		units          string
+		want           int
+		wantUnits      string
+		wantWaveHeight string
+		wantWindSpeed  string
+		wantHeight     float64
+	}{
+		{"", http.StatusOK, UNITS_IMPERIAL, "3-4.5 ft at 12 seconds 215 degrees", "5 mph", 3.8},
+		{"imperial", http.StatusOK, UNITS_IMPERIAL, "3-4.5 ft at 12 seconds 215 degrees", "5 mph", 3.8},
+		{"metric", http.StatusOK, UNITS_METRIC, "0.91-1.37 m at 12 seconds 215 degrees", "8 km/h", 1.15824},
+		{"kelvin", http.StatusBadRequest, "", "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.units, func(t *testing.T) {
+			useCache(t)
+
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+"&units="+tt.units, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var got ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if got.Units != tt.wantUnits || got.WaveHeight != tt.wantWaveHeight || got.WindSpeed != tt.wantWindSpeed {
+				t.Errorf("Units, WaveHeight, WindSpeed = %q, %q, %q", got.Units, got.WaveHeight, got.WindSpeed)
+			}
+			if math.Abs(got.WaveHeightFt-tt.wantHeight) > 1e-9 {
+				t.Errorf("WaveHeightFt = %v, want %v", got.WaveHeightFt, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestToMetricHeights(t *testing.T) {
+	resp := ForecastResponse{
+		Units:      UNITS_IMPERIAL,
+		Swells:     []Swell{{HeightFt: 10, PeriodSec: 12}},
+		TideEvents: []TideEvent{{Type: TIDE_HIGH, HeightFt: 10}},
+	}
+	got := toMetric(resp)
+
+	if math.Abs(got.Swells[0].HeightFt-3.048) > 1e-9 || got.Swells[0].PeriodSec != 12 {
+		t.Errorf("swell = %+v", got.Swells[0])
+	}
+	if math.Abs(got.TideEvents[0].HeightFt-3.048) > 1e-9 || got.TideEvents[0].Type != TIDE_HIGH {
+		t.Errorf("tide event = %+v", got.TideEvents[0])
+	}
+	// A cached response shares its slices, so they must be copied
+	if resp.Swells[0].HeightFt != 10 || resp.TideEvents[0].HeightFt != 10 {
+		t.Error("toMetric modified the original response")
+	}
+}
+
+func TestToMetricTemperatures(t *testing.T) {
+	tests := []struct {
+		name               string
+		waterF, airF       float64
+		wantWater, wantAir float64
+	}{
+		{"reported", 68, 86, 20, 30},
+		{"freezing", 32, 14, 0, -10},
+		{"not reported", 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toMetric(ForecastResponse{Units: UNITS_IMPERIAL, WaterTempF: tt.waterF, AirTempF: tt.airF})
+			if math.Abs(got.WaterTempF-tt.wantWater) > 1e-9 || math.Abs(got.AirTempF-tt.wantAir) > 1e-9 {
+				t.Errorf("water, air = %v, %v, want %v, %v", got.WaterTempF, got.AirTempF, tt.wantWater, tt.wantAir)
+			}
+		})
+	}
+}
+
+func TestToMetricWind(t *testing.T) {
+	got := toMetric(ForecastResponse{Units: UNITS_IMPERIAL, WindSpeed: "10 mph", WindSpeedMph: 10, WindGustMph: 10})
+	if math.Abs(got.WindSpeedMph-16.09344) > 1e-9 || math.Abs(got.WindGustMph-16.09344) > 1e-9 {
+		t.Errorf("WindSpeedMph, WindGustMph = %v, %v, want 16.09344", got.WindSpeedMph, got.WindGustMph)
+	}
+}
+
+// Costa Rican water is warmer than Californian, in either unit system
+func TestHandleForecastTemperatures(t *testing.T) {
+	for _, units := range []string{UNITS_IMPERIAL, UNITS_METRIC} {
+		t.Run(units, func(t *testing.T) {
+			useCache(t)
+			w := httptest.NewRecorder()
+			handleForecast(w, httptest.NewRequest(http.MethodGet, "/forecast?spotId="+malibu+","+tamarindo+"&units="+units, nil))
+
+			var got []ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || len(got) != 2 {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			wantMalibu := 62.0
+			if units == UNITS_METRIC {
+				wantMalibu = fToC(62)
+			}
+			if math.Abs(got[0].WaterTempF-wantMalibu) > 1e-9 {
+				t.Errorf("Malibu water = %v, want %v", got[0].WaterTempF, wantMalibu)
+			}
+			if got[1].WaterTempF <= got[0].WaterTempF || got[1].AirTempF <= got[0].AirTempF {
+				t.Errorf("Tamarindo %v/%v is not warmer than Malibu %v/%v", got[1].WaterTempF, got[1].AirTempF, got[0].WaterTempF, got[0].AirTempF)
+			}
+		})
+	}
+}
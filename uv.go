@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// UV index categories, following the WHO scale
+const (
+	UV_LOW       = "low"
+	UV_MODERATE  = "moderate"
+	UV_HIGH      = "high"
+	UV_VERY_HIGH = "very high"
+	UV_EXTREME   = "extreme"
+)
+
+// uvCategory buckets a UV index: below 3 is low, below 6 moderate, below 8
+// high, below 11 very high and anything above extreme
+func uvCategory(index float64) string {
+	switch {
+	case index < 3:
+		return UV_LOW
+	case index < 6:
+		return UV_MODERATE
+	case index < 8:
+		return UV_HIGH
+	case index < 11:
+		return UV_VERY_HIGH
+	default:
+		return UV_EXTREME
+	}
+}
+
+// mockUVIndex synthesizes a clear-sky UV index at t. It peaks at midday,
+// higher the closer the spot is to the equator, and is zero at night.
+func mockUVIndex(lat, lon float64, t time.Time) float64 {
+	sunrise, sunset, ok := sunTimes(lat, lon, t)
+	if !ok || t.Before(sunrise) || t.After(sunset) {
+		return 0
+	}
+	dayFraction := float64(t.Sub(sunrise)) / float64(sunset.Sub(sunrise))
+	latitudeFactor := math.Pow(math.Cos(lat*math.Pi/180), 2)
+	return math.Round(12*latitudeFactor*math.Sin(math.Pi*dayFraction)*10) / 10
+}
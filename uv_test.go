@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUVCategory(t *testing.T) {
+	tests := []struct {
+		index float64
+		want  string
+	}{
+		{0, UV_LOW},
+		{2.9, UV_LOW},
+		{3, UV_MODERATE},
+		{6, UV_HIGH},
+		{8, UV_VERY_HIGH},
+		{10.9, UV_VERY_HIGH},
+		{11, UV_EXTREME},
+	}
+	for _, tt := range tests {
+		if got := uvCategory(tt.index); got != tt.want {
+			t.Errorf("uvCategory(%v) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestMockUVIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		lat     float64
+		lon     float64
+		at      time.Time
+		wantMin float64
+		wantMax float64
+	}{
+		{"equator at noon", 0, 0, time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC), 11.5, 12},
+		{"malibu at noon", 34.03, -118.78, time.Date(2024, 6, 21, 20, 0, 0, 0, time.UTC), 7, 8.5},
+		{"malibu at midnight", 34.03, -118.78, time.Date(2024, 6, 21, 7, 0, 0, 0, time.UTC), 0, 0},
+		{"polar night", 78, 15, time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mockUVIndex(tt.lat, tt.lon, tt.at)
+			if got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("mockUVIndex() = %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Version of the response schema. Breaking changes bump it and are served
+// under a new /vN/ path prefix.
+const API_VERSION = "1"
+
+// Build information, set at link time with e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=abc1234 -X main.buildTime=2024-01-01T00:00:00Z"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+type VersionResponse struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	BuildTime  string `json:"buildTime"`
+	GoVersion  string `json:"goVersion"`
+	ApiVersion string `json:"apiVersion"`
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSONResponse(w, r, VersionResponse{
+		Version:    version,
+		Commit:     commit,
+		BuildTime:  buildTime,
+		GoVersion:  runtime.Version(),
+		ApiVersion: API_VERSION,
+	})
+}
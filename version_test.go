@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestHandleVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		commit  string
+		want    VersionResponse
+	}{
+		{"unset", "dev", "unknown", VersionResponse{Version: "dev", Commit: "unknown", BuildTime: "unknown", GoVersion: runtime.Version(), ApiVersion: API_VERSION}},
+		{"set at link time", "1.2.0", "abc1234", VersionResponse{Version: "1.2.0", Commit: "abc1234", BuildTime: "unknown", GoVersion: runtime.Version(), ApiVersion: API_VERSION}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &version, tt.version)
+			setForTest(t, &commit, tt.commit)
+
+			w := httptest.NewRecorder()
+			handleVersion(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			var fields map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			for _, key := range []string{"version", "commit", "buildTime", "goVersion"} {
+				if _, ok := fields[key]; !ok {
+					t.Errorf("body %s is missing %q", w.Body, key)
+				}
+			}
+			var got VersionResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if got != tt.want {
+				t.Errorf("version = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Longest a /forecast/watch request is held open before answering 204
+const WATCH_TIMEOUT = 60 * time.Second
+
+// forecastHash fingerprints the surf conditions in a forecast so a watcher
+// can tell whether a write to the cache actually changed them. Fields that
+// move with every fetch or with the clock, such as Timestamp, FreshUntil,
+// Trend, Source and the tide and sun times, are left out so a refetch of the
+// same conditions doesn't wake anyone.
+func forecastHash(resp ForecastResponse, found bool) string {
+	if !found {
+		return ""
+	}
+	body, _ := json.Marshal(struct {
+		WaveHeight      string
+		WindSpeed       string
+		WindDirection   string
+		Tide            string
+		WaveHeightFt    float64
+		WaveHeightMinFt float64
+		WaveHeightMaxFt float64
+		SwellPeriodSec  int
+		SwellDirection  int
+		Swells          []Swell
+		WindDegrees     int
+		WindGustMph     float64
+		WaterTempF      float64
+		AirTempF        float64
+		Score           int
+		Rating          string
+	}{
+		resp.WaveHeight, resp.WindSpeed, resp.WindDirection, resp.Tide,
+		resp.WaveHeightFt, resp.WaveHeightMinFt, resp.WaveHeightMaxFt,
+		resp.SwellPeriodSec, resp.SwellDirectionDeg, resp.Swells,
+		resp.WindDegrees, resp.WindGustMph, resp.WaterTempF, resp.AirTempF,
+		resp.Score, resp.Rating,
+	})
+	return computeETag(body)
+}
+
+// handleWatch long-polls a spot's cached forecast. It answers with the new
+// forecast once the cached one changes, or 204 No Content if it hasn't within
+// WATCH_TIMEOUT.
+func handleWatch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	spotID := r.URL.Query().Get("spotId")
+	if spotID == "" {
+		writeJSONError(w, http.StatusBadRequest, ERR_MISSING_SPOT_ID, "Missing spotId parameter")
+		return
+	}
+	if !validSpotID(spotID) {
+		writeJSONError(w, http.StatusBadRequest, ERR_INVALID_SPOT_ID, "invalid spotId format")
+		return
+	}
+	if _, ok := lookupSpot(r.Context(), spotID); !ok {
+		writeJSONError(w, http.StatusNotFound, ERR_UNKNOWN_SPOT, "unknown spotId")
+		return
+	}
+	if disabledSpots[spotID] {
+		writeFetchError(w, errSpotDisabled)
+		return
+	}
+
+	timeout := time.NewTimer(WATCH_TIMEOUT)
+	defer timeout.Stop()
+
+	// Take the watch before reading so a write in between isn't missed
+	changed := forecastCache.Watch(spotID)
+	initial := forecastHash(forecastCache.Peek(spotID))
+	for {
+		select {
+		case <-changed:
+			changed = forecastCache.Watch(spotID)
+			response, found := forecastCache.Peek(spotID)
+			if found && forecastHash(response, found) != initial {
+				writeForecastResponse(w, r, response)
+				return
+			}
+		case <-timeout.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
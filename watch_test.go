@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestForecastHash(t *testing.T) {
+	base := fakeForecast(malibu, 4)
+	tests := []struct {
+		name     string
+		change   func(*ForecastResponse)
+		wantSame bool
+	}{
+		{"unchanged", func(*ForecastResponse) {}, true},
+		{"new timestamp", func(r *ForecastResponse) { r.Timestamp += 600; r.FreshUntil += 600 }, true},
+		{"new trend", func(r *ForecastResponse) { r.Trend = TREND_BUILDING }, true},
+		{"new source", func(r *ForecastResponse) { r.Source = SOURCE_MOCK }, true},
+		{"bigger waves", func(r *ForecastResponse) { r.WaveHeightFt++ }, false},
+		{"wider range", func(r *ForecastResponse) { r.WaveHeightMaxFt++ }, false},
+		{"wind change", func(r *ForecastResponse) { r.WindSpeed = "20 mph" }, false},
+		{"new rating", func(r *ForecastResponse) { r.Rating = RATING_EPIC }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base
+			tt.change(&changed)
+			if same := forecastHash(base, true) == forecastHash(changed, true); same != tt.wantSame {
+				t.Errorf("hashes equal = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	if got := forecastHash(base, false); got != "" {
+		t.Errorf("forecastHash of a missing forecast = %q, want empty", got)
+	}
+}
+
+func TestHandleWatchRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantErr string
+	}{
+		{"missing spot", "", http.StatusBadRequest, "Missing spotId parameter"},
+		{"invalid spot", "?spotId=nope", http.StatusBadRequest, "invalid spotId format"},
+		{"unknown spot", "?spotId=" + unknownSpotID, http.StatusNotFound, "unknown spotId"},
+		{"disabled spot", "?spotId=" + huntington, http.StatusServiceUnavailable, "Forecasts for this spot are temporarily disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNegativeCache(t)
+			setForTest(t, &disabledSpots, map[string]bool{huntington: true})
+
+			w := httptest.NewRecorder()
+			handleWatch(w, httptest.NewRequest(http.MethodGet, "/forecast/watch"+tt.query, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if body["error"] != tt.wantErr {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleWatchWakesOnChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		writes  []float64
+		wantFt  float64
+		initial bool
+	}{
+		{"first forecast", []float64{4}, 4, false},
+		{"refetch of the same conditions is ignored", []float64{3, 5}, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := useCache(t)
+			if tt.initial {
+				cache.Set(malibu, fakeForecast(malibu, 3), time.Now().Unix()+60)
+			}
+
+			done := make(chan *httptest.ResponseRecorder)
+			go func() {
+				w := httptest.NewRecorder()
+				handleWatch(w, httptest.NewRequest(http.MethodGet, "/forecast/watch?spotId="+malibu, nil))
+				done <- w
+			}()
+
+			for _, height := range tt.writes {
+				waitForWatcher(t, cache, malibu)
+				cache.Set(malibu, fakeForecast(malibu, height), time.Now().Unix()+60)
+			}
+			w := <-done
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			var response ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding body %q: %v", w.Body, err)
+			}
+			if response.WaveHeightFt != tt.wantFt {
+				t.Errorf("WaveHeightFt = %v, want %v", response.WaveHeightFt, tt.wantFt)
+			}
+		})
+	}
+}
+
+// waitForWatcher blocks until something is watching spotID, so a write
+// isn't made before the watcher takes its watch
+func waitForWatcher(t *testing.T, cache *forecastCacheStore, spotID string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		cache.mu.Lock()
+		_, watched := cache.watchers[spotID]
+		cache.mu.Unlock()
+		if watched {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("nothing is watching %s", spotID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandleWatchClientGone(t *testing.T) {
+	useCache(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/forecast/watch?spotId="+malibu, nil).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		handleWatch(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handleWatch kept waiting after the client went away")
+	}
+}